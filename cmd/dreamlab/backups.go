@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"dreamlab/internal/dreamlab"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// snapshotAPI is the part of the ec2 client used to list snapshots
+type snapshotAPI interface {
+	DescribeSnapshots(context.Context, *ec2.DescribeSnapshotsInput, ...func(*ec2.Options)) (*ec2.DescribeSnapshotsOutput, error)
+}
+
+func runBackups(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("backups", flag.ExitOnError)
+	limit := flags.Int("n", 10, "number of snapshots to list")
+	if len(args) < 1 || args[0] != "list" {
+		return errors.New("usage: dreamlab backups list [-n count] <host>")
+	}
+	flags.Parse(args[1:])
+	if flags.NArg() != 1 {
+		return errors.New("usage: dreamlab backups list [-n count] <host>")
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return err
+	}
+	return listBackups(ctx, os.Stdout, ec2.NewFromConfig(cfg), flags.Arg(0), *limit, time.Now())
+}
+
+// listBackups writes a table of the host's most recent volume snapshots,
+// newest first.
+func listBackups(ctx context.Context, w io.Writer, api snapshotAPI, host string, limit int, now time.Time) error {
+	input := &ec2.DescribeSnapshotsInput{
+		OwnerIds: []string{"self"},
+		Filters: []types.Filter{
+			{Name: aws.String("tag:" + dreamlab.HostTag), Values: []string{host}},
+		},
+	}
+	var snaps []types.Snapshot
+	paginator := ec2.NewDescribeSnapshotsPaginator(api, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("listing snapshots for %q: %w", host, err)
+		}
+		snaps = append(snaps, page.Snapshots...)
+	}
+	if len(snaps) == 0 {
+		return fmt.Errorf("no snapshots found for host %q", host)
+	}
+	sort.Slice(snaps, func(i, j int) bool {
+		return aws.ToTime(snaps[i].StartTime).After(aws.ToTime(snaps[j].StartTime))
+	})
+	if limit > 0 && len(snaps) > limit {
+		snaps = snaps[:limit]
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SNAPSHOT\tVOLUME\tSIZE\tSTATE\tSTARTED\tAGE")
+	for _, s := range snaps {
+		started := aws.ToTime(s.StartTime)
+		fmt.Fprintf(tw, "%s\t%s\t%dGiB\t%s\t%s\t%s\n",
+			aws.ToString(s.SnapshotId),
+			aws.ToString(s.VolumeId),
+			aws.ToInt32(s.VolumeSize),
+			s.State,
+			started.Format(time.RFC3339),
+			formatAge(now.Sub(started)),
+		)
+	}
+	return tw.Flush()
+}
+
+// formatAge formats d as days and hours
+func formatAge(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	if days > 0 {
+		return fmt.Sprintf("%dd%dh", days, hours)
+	}
+	return fmt.Sprintf("%dh%dm", hours, int(d.Minutes())%60)
+}
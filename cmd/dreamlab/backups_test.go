@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"dreamlab/internal/dreamlab"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// fakeSnapshots applies DescribeSnapshots' tag filters to its snapshots,
+// and returns them two per page.
+type fakeSnapshots struct {
+	snapshots []types.Snapshot
+}
+
+func (f *fakeSnapshots) DescribeSnapshots(_ context.Context, in *ec2.DescribeSnapshotsInput, _ ...func(*ec2.Options)) (*ec2.DescribeSnapshotsOutput, error) {
+	if !slices.Equal(in.OwnerIds, []string{"self"}) {
+		return nil, fmt.Errorf("owners are %v", in.OwnerIds)
+	}
+	var matched []types.Snapshot
+	for _, s := range f.snapshots {
+		if matchesFilters(s, in.Filters) {
+			matched = append(matched, s)
+		}
+	}
+	start := 0
+	if in.NextToken != nil {
+		fmt.Sscan(*in.NextToken, &start)
+	}
+	end := min(start+2, len(matched))
+	out := &ec2.DescribeSnapshotsOutput{Snapshots: matched[start:end]}
+	if end < len(matched) {
+		out.NextToken = aws.String(fmt.Sprint(end))
+	}
+	return out, nil
+}
+
+func matchesFilters(s types.Snapshot, filters []types.Filter) bool {
+	for _, f := range filters {
+		key, ok := strings.CutPrefix(aws.ToString(f.Name), "tag:")
+		if !ok {
+			return false
+		}
+		i := slices.IndexFunc(s.Tags, func(tag types.Tag) bool { return aws.ToString(tag.Key) == key })
+		if i < 0 || !slices.Contains(f.Values, aws.ToString(s.Tags[i].Value)) {
+			return false
+		}
+	}
+	return true
+}
+
+func testSnapshot(id, host string, started time.Time) types.Snapshot {
+	return types.Snapshot{
+		SnapshotId: aws.String(id),
+		VolumeId:   aws.String("vol-" + host),
+		VolumeSize: aws.Int32(32),
+		State:      types.SnapshotStateCompleted,
+		StartTime:  aws.Time(started),
+		Tags:       []types.Tag{{Key: aws.String(dreamlab.HostTag), Value: aws.String(host)}},
+	}
+}
+
+func TestListBackups(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	api := &fakeSnapshots{snapshots: []types.Snapshot{
+		testSnapshot("snap-coder-2", "coder", now.Add(-2*day)),
+		testSnapshot("snap-data-3", "data", now.Add(-3*day)),
+		testSnapshot("snap-coder-1", "coder", now.Add(-1*day-3*time.Hour)),
+		testSnapshot("snap-coder-7", "coder", now.Add(-7*day)),
+		testSnapshot("snap-coder-0", "coder", now.Add(-30*time.Minute)),
+		{SnapshotId: aws.String("snap-untagged"), StartTime: aws.Time(now)},
+	}}
+	tests := []struct {
+		name  string
+		host  string
+		limit int
+		want  []string // snapshot and age of each row
+		err   string
+	}{
+		{
+			name: "newest first",
+			host: "coder",
+			want: []string{"snap-coder-0 0h30m", "snap-coder-1 1d3h", "snap-coder-2 2d0h", "snap-coder-7 7d0h"},
+		},
+		{
+			name:  "limit",
+			host:  "coder",
+			limit: 2,
+			want:  []string{"snap-coder-0 0h30m", "snap-coder-1 1d3h"},
+		},
+		{
+			name: "other host",
+			host: "data",
+			want: []string{"snap-data-3 3d0h"},
+		},
+		{
+			name: "no snapshots",
+			host: "workspace",
+			err:  `no snapshots found for host "workspace"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &strings.Builder{}
+			err := listBackups(context.Background(), out, api, tt.host, tt.limit, now)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error is %v, want %q", err, tt.err)
+				}
+				if out.Len() != 0 {
+					t.Errorf("wrote output:\n%s", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if !strings.HasPrefix(lines[0], "SNAPSHOT") {
+				t.Fatalf("no header:\n%s", out)
+			}
+			var got []string
+			for _, line := range lines[1:] {
+				fields := strings.Fields(line)
+				got = append(got, fields[0]+" "+fields[len(fields)-1])
+				if fields[1] != "vol-"+tt.host || fields[2] != "32GiB" || fields[3] != "completed" {
+					t.Errorf("row %q", line)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("rows are %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// objectAPI is the part of the s3 client used to read objects
+type objectAPI interface {
+	GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// acmeStore is the content of traefik's acme.json: certificates by
+// resolver name.
+type acmeStore map[string]struct {
+	Certificates []struct {
+		Domain struct {
+			Main string   `json:"main"`
+			SANs []string `json:"sans"`
+		} `json:"domain"`
+		Certificate []byte `json:"certificate"` // PEM, base64 encoded in json
+	} `json:"Certificates"`
+}
+
+func runCerts(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("certs", flag.ExitOnError)
+	warn := flags.Duration("warn", 14*24*time.Hour, "warn about certificates expiring within this duration")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: dreamlab certs [-warn 336h] <acme.json | s3://bucket/prefix/host/acme.json>")
+	}
+	src := flags.Arg(0)
+	if !strings.HasPrefix(src, "s3://") {
+		b, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		return listCerts(os.Stdout, b, time.Now(), *warn)
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return err
+	}
+	b, err := readObject(ctx, s3.NewFromConfig(cfg), src)
+	if err != nil {
+		return err
+	}
+	return listCerts(os.Stdout, b, time.Now(), *warn)
+}
+
+// readObject reads the object at an s3://bucket/key URL.
+func readObject(ctx context.Context, api objectAPI, url string) ([]byte, error) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(url, "s3://"), "/")
+	out, err := api.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", url, err)
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+// listCerts writes a table of the certificates in acme.json, soonest
+// expiring first. Certificates expiring within warn are marked and listed
+// as warnings after the table.
+func listCerts(w io.Writer, acmeJSON []byte, now time.Time, warn time.Duration) error {
+	var store acmeStore
+	if err := json.Unmarshal(acmeJSON, &store); err != nil {
+		return fmt.Errorf("parsing acme.json: %w", err)
+	}
+	type certInfo struct {
+		resolver string
+		domains  []string
+		issuer   string
+		expires  time.Time
+	}
+	var certs []certInfo
+	for resolver, r := range store {
+		for _, c := range r.Certificates {
+			block, _ := pem.Decode(c.Certificate)
+			if block == nil {
+				return fmt.Errorf("%s: certificate for %q is not PEM encoded", resolver, c.Domain.Main)
+			}
+			x, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return fmt.Errorf("%s: certificate for %q: %w", resolver, c.Domain.Main, err)
+			}
+			certs = append(certs, certInfo{
+				resolver: resolver,
+				domains:  append([]string{c.Domain.Main}, c.Domain.SANs...),
+				issuer:   x.Issuer.CommonName,
+				expires:  x.NotAfter,
+			})
+		}
+	}
+	if len(certs) == 0 {
+		return errors.New("no certificates in acme.json")
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		return certs[i].expires.Before(certs[j].expires)
+	})
+	var warnings []string
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESOLVER\tDOMAINS\tISSUER\tEXPIRES\tLEFT\tSTATUS")
+	for _, c := range certs {
+		left := c.expires.Sub(now)
+		status := "ok"
+		switch {
+		case left <= 0:
+			status = "expired"
+			left = 0
+			warnings = append(warnings, fmt.Sprintf("warning: certificate for %s expired %s", c.domains[0], c.expires.Format(time.RFC3339)))
+		case left < warn:
+			status = "expiring"
+			warnings = append(warnings, fmt.Sprintf("warning: certificate for %s expires in %s", c.domains[0], formatAge(left)))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			c.resolver,
+			strings.Join(c.domains, ","),
+			c.issuer,
+			c.expires.Format(time.RFC3339),
+			formatAge(left),
+			status,
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, msg := range warnings {
+		fmt.Fprintln(w, msg)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListCerts(t *testing.T) {
+	b, err := os.ReadFile("testdata/acme.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	out := &strings.Builder{}
+	if err := listCerts(out, b, now, 14*24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("listCerts() =\n%s", out)
+	}
+	// soonest expiring first
+	rows := []struct{ domains, expires, left, status string }{
+		{"auth.dreamlab.ucsb.edu", "2026-10-01T12:00:00Z", "0h0m", "expired"},
+		{"traefik.coder.dreamlab.ucsb.edu", "2026-10-26T12:00:00Z", "7d0h", "expiring"},
+		{"coder.dreamlab.ucsb.edu,*.coder.dreamlab.ucsb.edu", "2026-12-19T12:00:00Z", "61d0h", "ok"},
+	}
+	for i, want := range rows {
+		fields := strings.Fields(lines[i+1])
+		got := []string{fields[0], fields[1], strings.Join(fields[2:len(fields)-3], " "), fields[len(fields)-3], fields[len(fields)-2], fields[len(fields)-1]}
+		exp := []string{"letsencrypt", want.domains, "Test ACME CA", want.expires, want.left, want.status}
+		if strings.Join(got, "|") != strings.Join(exp, "|") {
+			t.Errorf("row %d = %v, want %v", i, got, exp)
+		}
+	}
+	warnings := []string{
+		"warning: certificate for auth.dreamlab.ucsb.edu expired 2026-10-01T12:00:00Z",
+		"warning: certificate for traefik.coder.dreamlab.ucsb.edu expires in 7d0h",
+	}
+	for i, want := range warnings {
+		if lines[4+i] != want {
+			t.Errorf("warning %d = %q, want %q", i, lines[4+i], want)
+		}
+	}
+}
+
+func TestListCertsErrors(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name, acmeJSON, wantErr string
+	}{
+		{"invalid json", `{`, "parsing acme.json"},
+		{"no certificates", `{"letsencrypt": {"Certificates": null}}`, "no certificates"},
+		// "bm90IHBlbQ==" is base64 for "not pem"
+		{"not pem", `{"letsencrypt": {"Certificates": [{"domain": {"main": "a.example.edu"}, "certificate": "bm90IHBlbQ=="}]}}`, `certificate for "a.example.edu" is not PEM encoded`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := listCerts(&strings.Builder{}, []byte(tt.acmeJSON), now, time.Hour)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("listCerts() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"dreamlab/internal/dreamlab/fcos"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+func runFCOSAMI(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("fcos-ami", flag.ExitOnError)
+	stream := flags.String("stream", "stable", "fedora coreos stream")
+	arch := flags.String("arch", "aarch64", "architecture: aarch64 or x86_64")
+	region := flags.String("region", "us-west-2", "aws region")
+	file := flags.String("file", "", "read stream metadata from a local file instead of downloading it")
+	pin := flags.String("pin", "", "set this stack config key to the AMI (e.g., coder_instance_ami)")
+	flags.Parse(args)
+	var (
+		meta *fcos.Stream
+		err  error
+	)
+	if *file != "" {
+		meta, err = fcos.ParseFile(*file)
+	} else {
+		meta, err = fcos.Fetch(ctx, *stream)
+	}
+	if err != nil {
+		return err
+	}
+	img, err := meta.AMI(*region, *arch)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\t%s %s %s\n", img.Image, meta.Stream, *arch, img.Release)
+	if *pin == "" {
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, "pulumi", "config", "set", *pin, img.Image)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pinning %s: %w", *pin, err)
+	}
+	return nil
+}
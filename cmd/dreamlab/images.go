@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/registry"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// digestAPI resolves image tags to digests
+type digestAPI interface {
+	Digest(ctx context.Context, repo, tag string) (string, error)
+}
+
+func runImages(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("images", flag.ExitOnError)
+	manifest := flags.String("manifest", dreamlab.ImageManifestFile, "image manifest file")
+	pin := flags.Bool("pin", false, "update the manifest with the current digests")
+	if len(args) < 1 || args[0] != "check" {
+		return errors.New("usage: dreamlab images check [-manifest file] [-pin]")
+	}
+	flags.Parse(args[1:])
+	return checkImages(ctx, os.Stdout, &registry.Client{}, *manifest, *pin)
+}
+
+// checkImages compares the manifest's pinned digests to the digests the
+// images' tags currently refer to. If pin is true, the manifest file is
+// updated instead of returning an error for outdated images.
+func checkImages(ctx context.Context, w io.Writer, api digestAPI, manifest string, pin bool) error {
+	b, err := os.ReadFile(manifest)
+	if err != nil {
+		return err
+	}
+	images, err := dreamlab.ParseImageManifest(b)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	changed := false
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "IMAGE\tTAG\tPINNED\tCURRENT\tSTATUS")
+	for i, img := range images {
+		current, err := api.Digest(ctx, img.Repo, img.Tag)
+		status := "ok"
+		switch {
+		case err != nil:
+			status = "error"
+			errs = append(errs, err)
+		case img.Digest == "":
+			status = "unpinned"
+		case img.Digest != current:
+			status = "outdated"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", img.Name, img.Tag, short(img.Digest), short(current), status)
+		if status == "ok" || status == "error" {
+			continue
+		}
+		if pin {
+			images[i].Digest = current
+			changed = true
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s:%s is %s", img.Name, img.Tag, status))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if changed {
+		out, err := json.MarshalIndent(images, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(manifest, append(out, '\n'), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "updated", manifest)
+	}
+	return errors.Join(errs...)
+}
+
+// short abbreviates a sha256 digest for display
+func short(digest string) string {
+	if len(digest) > len("sha256:")+12 {
+		return digest[:len("sha256:")+12]
+	}
+	if digest == "" {
+		return "-"
+	}
+	return digest
+}
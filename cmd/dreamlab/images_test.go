@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/registry"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testToken = "anonymous-token"
+
+// newTestRegistry starts a registry that requires an anonymous bearer token
+// and serves the tags' digests (keyed by "name:tag").
+func newTestRegistry(t *testing.T, digests map[string]string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	mux.HandleFunc("GET /token", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("scope") == "" {
+			http.Error(w, "no scope", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": testToken})
+	})
+	mux.HandleFunc("HEAD /v2/", func(w http.ResponseWriter, r *http.Request) {
+		name, tag, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/"), "/manifests/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:%s:pull"`, srv.URL, name))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.Contains(r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json") {
+			http.Error(w, "index not accepted", http.StatusNotAcceptable)
+			return
+		}
+		digest, ok := digests[name+":"+tag]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+	})
+	return srv
+}
+
+// writeManifest writes an image manifest for images in the test registry
+func writeManifest(t *testing.T, host string, images []dreamlab.Image) string {
+	t.Helper()
+	for i := range images {
+		images[i].Repo = host + "/" + images[i].Repo
+		images[i].Arches = []string{"aarch64", "x86_64"}
+	}
+	b, err := json.Marshal(images)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "images.json")
+	if err := os.WriteFile(name, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestCheckImages(t *testing.T) {
+	const (
+		current = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		old     = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	)
+	srv := newTestRegistry(t, map[string]string{
+		"library/traefik:v3.4": current,
+		"coder/coder:v2.30.5":  current,
+		"lab/ocfl-server:v1":   current,
+	})
+	host := strings.TrimPrefix(srv.URL, "http://")
+	api := &registry.Client{HTTP: srv.Client(), PlainHTTP: true}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		images  []dreamlab.Image
+		pin     bool
+		status  string
+		wantErr string
+		pinned  string // digest in the manifest afterwards
+	}{
+		{
+			name:   "ok",
+			images: []dreamlab.Image{{Name: "traefik", Repo: "library/traefik", Tag: "v3.4", Digest: current}},
+			status: "ok",
+			pinned: current,
+		},
+		{
+			name:    "outdated",
+			images:  []dreamlab.Image{{Name: "coder", Repo: "coder/coder", Tag: "v2.30.5", Digest: old}},
+			status:  "outdated",
+			wantErr: "coder:v2.30.5 is outdated",
+			pinned:  old,
+		},
+		{
+			name:    "unpinned",
+			images:  []dreamlab.Image{{Name: "ocfl-server", Repo: "lab/ocfl-server", Tag: "v1"}},
+			status:  "unpinned",
+			wantErr: "ocfl-server:v1 is unpinned",
+		},
+		{
+			name:   "pin outdated",
+			images: []dreamlab.Image{{Name: "coder", Repo: "coder/coder", Tag: "v2.30.5", Digest: old}},
+			pin:    true,
+			status: "outdated",
+			pinned: current,
+		},
+		{
+			name:   "pin unpinned",
+			images: []dreamlab.Image{{Name: "ocfl-server", Repo: "lab/ocfl-server", Tag: "v1"}},
+			pin:    true,
+			status: "unpinned",
+			pinned: current,
+		},
+		{
+			name:    "unknown tag",
+			images:  []dreamlab.Image{{Name: "traefik", Repo: "library/traefik", Tag: "v9", Digest: old}},
+			pin:     true,
+			status:  "error",
+			wantErr: "404 Not Found",
+			pinned:  old,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := writeManifest(t, host, tt.images)
+			out := &strings.Builder{}
+			err := checkImages(ctx, out, api, manifest, tt.pin)
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("checkImages() error = %v, want %q", err, tt.wantErr)
+			}
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if fields := strings.Fields(lines[1]); fields[len(fields)-1] != tt.status {
+				t.Errorf("status = %q, want %q", fields[len(fields)-1], tt.status)
+			}
+			b, err := os.ReadFile(manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			images, err := dreamlab.ParseImageManifest(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if images[0].Digest != tt.pinned {
+				t.Errorf("manifest digest = %q, want %q", images[0].Digest, tt.pinned)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Command dreamlab has tools for operating the lab's infrastructure.
+//
+//	dreamlab backups list <host>
+//	dreamlab certs [-warn 336h] <acme.json | s3 url>
+//	dreamlab fcos-ami [-stream stable] [-arch aarch64] [-pin config-key]
+//	dreamlab images check [-pin]
+//	dreamlab ocfl check [-content] <dir | s3 url>
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"backups": {
+		usage: "backups list <host>",
+		run:   runBackups,
+	},
+	"certs": {
+		usage: "certs [-warn 336h] <acme.json | s3://bucket/prefix/host/acme.json>",
+		run:   runCerts,
+	},
+	"fcos-ami": {
+		usage: "fcos-ami [-stream stable] [-arch aarch64] [-region us-west-2] [-file stream.json] [-pin config-key]",
+		run:   runFCOSAMI,
+	},
+	"images": {
+		usage: "images check [-manifest file] [-pin]",
+		run:   runImages,
+	},
+	"ocfl": {
+		usage: "ocfl check [-content] <dir | s3://bucket/prefix>",
+		run:   runOCFL,
+	},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if err := cmd.run(ctx, os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "dreamlab:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, "  dreamlab", commands[name].usage)
+	}
+}
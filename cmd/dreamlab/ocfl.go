@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"dreamlab/internal/dreamlab/ocflroot"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// bucketAPI is the part of the s3 client used to read a storage root
+type bucketAPI interface {
+	objectAPI
+	ListObjectsV2(context.Context, *s3.ListObjectsV2Input, ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+}
+
+func runOCFL(ctx context.Context, args []string) error {
+	const usage = "usage: dreamlab ocfl check [-content] <dir | s3://bucket/prefix>"
+	flags := flag.NewFlagSet("ocfl", flag.ExitOnError)
+	content := flags.Bool("content", false, "verify content file digests (reads every file)")
+	if len(args) < 1 || args[0] != "check" {
+		return errors.New(usage)
+	}
+	flags.Parse(args[1:])
+	if flags.NArg() != 1 {
+		return errors.New(usage)
+	}
+	root := flags.Arg(0)
+	var fsys fs.FS
+	if strings.HasPrefix(root, "s3://") {
+		cfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return err
+		}
+		fsys = newS3FS(ctx, s3.NewFromConfig(cfg), root)
+	} else {
+		fsys = os.DirFS(root)
+	}
+	return checkOCFL(ctx, os.Stdout, fsys, root, ocflroot.Options{Content: *content})
+}
+
+// checkOCFL writes a json report of the storage root's problems. It returns
+// an error if the storage root isn't valid.
+func checkOCFL(ctx context.Context, w io.Writer, fsys fs.FS, root string, opts ocflroot.Options) error {
+	report := ocflroot.Check(ctx, fsys, root, opts)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	if !report.Valid {
+		return fmt.Errorf("%s: %d errors", root, len(report.Errors))
+	}
+	return nil
+}
+
+// s3FS is a read-only fs.FS of the objects under an s3://bucket/prefix URL.
+// Directories are the prefix's common prefixes ("/" delimited); they can be
+// read with fs.ReadDir but not opened.
+type s3FS struct {
+	ctx    context.Context
+	api    bucketAPI
+	bucket string
+	prefix string // "" or ending in "/"
+}
+
+func newS3FS(ctx context.Context, api bucketAPI, url string) *s3FS {
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(url, "s3://"), "/")
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix += "/"
+	}
+	return &s3FS{ctx: ctx, api: api, bucket: bucket, prefix: prefix}
+}
+
+func (f *s3FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	out, err := f.api.GetObject(f.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(f.bucket),
+		Key:    aws.String(f.prefix + name),
+	})
+	if err != nil {
+		var noKey *types.NoSuchKey
+		if errors.As(err, &noKey) {
+			err = fs.ErrNotExist
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &s3File{
+		ReadCloser: out.Body,
+		info: s3Entry{
+			name:    path.Base(name),
+			size:    aws.ToInt64(out.ContentLength),
+			modTime: aws.ToTime(out.LastModified),
+		},
+	}, nil
+}
+
+func (f *s3FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	prefix := f.prefix
+	if name != "." {
+		prefix += name + "/"
+	}
+	var entries []fs.DirEntry
+	pages := s3.NewListObjectsV2Paginator(f.api, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(f.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(f.ctx)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		for _, p := range page.CommonPrefixes {
+			dir := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(p.Prefix), prefix), "/")
+			entries = append(entries, s3Entry{name: dir, dir: true})
+		}
+		for _, obj := range page.Contents {
+			// skip "directory" placeholder objects
+			if file := strings.TrimPrefix(aws.ToString(obj.Key), prefix); file != "" {
+				entries = append(entries, s3Entry{
+					name:    file,
+					size:    aws.ToInt64(obj.Size),
+					modTime: aws.ToTime(obj.LastModified),
+				})
+			}
+		}
+	}
+	// s3 has no empty directories
+	if len(entries) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+type s3File struct {
+	io.ReadCloser
+	info s3Entry
+}
+
+func (f *s3File) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+// s3Entry is an object or common prefix, as a fs.DirEntry and fs.FileInfo
+type s3Entry struct {
+	name    string
+	dir     bool
+	size    int64
+	modTime time.Time
+}
+
+func (e s3Entry) Name() string               { return e.name }
+func (e s3Entry) IsDir() bool                { return e.dir }
+func (e s3Entry) Type() fs.FileMode          { return e.Mode().Type() }
+func (e s3Entry) Info() (fs.FileInfo, error) { return e, nil }
+func (e s3Entry) Size() int64                { return e.size }
+func (e s3Entry) ModTime() time.Time         { return e.modTime }
+func (e s3Entry) Sys() any                   { return nil }
+
+func (e s3Entry) Mode() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
@@ -2,6 +2,7 @@ package coder
 
 import (
 	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/quadlet"
 	_ "embed"
 	"fmt"
 	"path/filepath"
@@ -11,7 +12,6 @@ import (
 	butaneConfig "github.com/coreos/butane/config"
 	"github.com/coreos/butane/config/common"
 	"github.com/hashicorp/go-multierror"
-	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ebs"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/route53"
@@ -28,9 +28,6 @@ const policyEC2AssumeRole = `{
 	}]
 }`
 
-//go:embed policy.json
-var awsPolicyCoder string
-
 //go:embed butane.yml
 var butaneYML string
 
@@ -38,16 +35,40 @@ type Config struct {
 	VPC          *dreamlab.AWSVPC
 	DNS          *dreamlab.DNS
 	Hostname     string
-	InstanceAMI  string // should be fedora coreos
-	InstanceType string // shoube be arm64
+	InstanceAMI  string // should be fedora coreos; if empty, FCOSStream's current AMI is used
+	FCOSStream   string // default: stable
+	Updates      *dreamlab.UpdatesConfig
+	InstanceType string // must match the AMI's architecture
+	Backups      *dreamlab.BackupConfig
+	AutoUpdate   *dreamlab.AutoUpdateConfig // containers podman auto-updates by tag
+	Traefik      *dreamlab.TraefikConfig
+	// persistent volume for container volumes and additional volumes
+	VarVolume dreamlab.VolumeConfig
+	Volumes   []dreamlab.VolumeConfig
+	// encrypt volumes with this KMS key instead of the AWS managed key
+	KMSKeyArn pulumi.StringInput
+	// create the persistent volume from this snapshot (snap-...)
+	RestoreFromSnapshot string
+
+	// instance types and AMIs coder may use for aws workspaces
+	WorkspaceInstanceTypes []string
+	WorkspaceAMIs          []string
+	// bucket aws workspaces can read shared datasets from
+	WorkspaceDatasetsBucket string
 }
 
 func New(ctx *pulumi.Context, resource string, coderConfig *Config) error {
+	if len(coderConfig.WorkspaceInstanceTypes) == 0 {
+		return fmt.Errorf("%s: no workspace instance types configured", resource)
+	}
+	if len(coderConfig.WorkspaceAMIs) == 0 {
+		return fmt.Errorf("%s: no workspace AMIs configured", resource)
+	}
 	sgResource := resource + "-sg"
 	sg, err := ec2.NewSecurityGroup(ctx, sgResource, &ec2.SecurityGroupArgs{
 		Name:  pulumi.String(sgResource),
 		VpcId: coderConfig.VPC.Vpc.ID(),
-		Ingress: &ec2.SecurityGroupIngressArray{
+		Ingress: append(ec2.SecurityGroupIngressArray{
 			&ec2.SecurityGroupIngressArgs{
 				FromPort:       pulumi.Int(22),
 				ToPort:         pulumi.Int(22),
@@ -69,7 +90,7 @@ func New(ctx *pulumi.Context, resource string, coderConfig *Config) error {
 				CidrBlocks:     pulumi.StringArray{pulumi.String("0.0.0.0/0")},
 				Ipv6CidrBlocks: pulumi.StringArray{pulumi.String("::/0")},
 			},
-		},
+		}, coderConfig.Traefik.Ingress(coderConfig.VPC)...),
 		Egress: &ec2.SecurityGroupEgressArray{
 			&ec2.SecurityGroupEgressArgs{
 				FromPort:       pulumi.Int(0),
@@ -103,10 +124,14 @@ func New(ctx *pulumi.Context, resource string, coderConfig *Config) error {
 	if err != nil {
 		return err
 	}
+	workspace, err := newWorkspaceResources(ctx, resource, coderConfig)
+	if err != nil {
+		return err
+	}
 	policyResource := roleResource + "-policy"
 	_, err = iam.NewRolePolicy(ctx, policyResource, &iam.RolePolicyArgs{
 		Role:   role.Name,
-		Policy: pulumi.String(awsPolicyCoder),
+		Policy: policy(coderConfig, workspace),
 	})
 	if err != nil {
 		return err
@@ -119,23 +144,36 @@ func New(ctx *pulumi.Context, resource string, coderConfig *Config) error {
 		return err
 	}
 	// persistent storage
-	varVolRescource := resource + "-var"
-	varVol, err := ebs.NewVolume(ctx, varVolRescource, &ebs.VolumeArgs{
-		AvailabilityZone: coderConfig.VPC.Public.AvailabilityZone,
-		Size:             pulumi.IntPtr(64),
-		Type:             pulumi.StringPtr("gp3"),
+	vols, err := dreamlab.NewHostVolumes(ctx, resource, &dreamlab.HostVolumesArgs{
+		Hostname:            coderConfig.Hostname,
+		AvailabilityZone:    coderConfig.VPC.Public.AvailabilityZone,
+		Var:                 coderConfig.VarVolume,
+		Extra:               coderConfig.Volumes,
+		KMSKeyArn:           coderConfig.KMSKeyArn,
+		RestoreFromSnapshot: coderConfig.RestoreFromSnapshot,
 	})
 	if err != nil {
 		return err
 	}
-	userData, err := ignition(ctx, coderConfig)
+	if err := dreamlab.NewBackupPolicy(ctx, resource, coderConfig.Hostname, coderConfig.Backups); err != nil {
+		return err
+	}
+	arch, err := dreamlab.InstanceArch(coderConfig.InstanceType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", resource, err)
+	}
+	ami, instanceOpts, err := dreamlab.InstanceAMI(ctx, coderConfig.InstanceAMI, coderConfig.FCOSStream, arch)
+	if err != nil {
+		return err
+	}
+	userData, err := ignition(ctx, coderConfig, vols, arch)
 	if err != nil {
 		return err
 	}
 	instanceArgs := &ec2.InstanceArgs{
 		IamInstanceProfile:  profile.Name,
 		SubnetId:            coderConfig.VPC.Public.ID(),
-		Ami:                 pulumi.String(coderConfig.InstanceAMI),
+		Ami:                 pulumi.String(ami),
 		InstanceType:        pulumi.String(coderConfig.InstanceType),
 		KeyName:             kp.KeyName,
 		VpcSecurityGroupIds: pulumi.StringArray{sg.ID()},
@@ -146,18 +184,13 @@ func New(ctx *pulumi.Context, resource string, coderConfig *Config) error {
 		UserData:                userData,
 		UserDataReplaceOnChange: pulumi.Bool(true),
 	}
-	inst, err := ec2.NewInstance(ctx, resource, instanceArgs, pulumi.DeleteBeforeReplace(true))
+	instanceOpts = append(instanceOpts, pulumi.DeleteBeforeReplace(true))
+	inst, err := ec2.NewInstance(ctx, resource, instanceArgs, instanceOpts...)
 	if err != nil {
 		return err
 	}
-	// Attach the volume to the existing EC2 instance.
-	_, err = ec2.NewVolumeAttachment(ctx, varVolRescource+"-attach", &ec2.VolumeAttachmentArgs{
-		InstanceId:                  inst.ID(),
-		VolumeId:                    varVol.ID(),
-		DeviceName:                  pulumi.String("/dev/sdf"),
-		StopInstanceBeforeDetaching: pulumi.BoolPtr(true),
-	}, pulumi.DeleteBeforeReplace(true))
-	if err != nil {
+	// Attach the volumes to the existing EC2 instance.
+	if err := dreamlab.AttachHostVolumes(ctx, resource, inst.ID(), vols); err != nil {
 		return err
 	}
 	eiPResource := resource + "-eip"
@@ -220,9 +253,36 @@ func New(ctx *pulumi.Context, resource string, coderConfig *Config) error {
 	return nil
 }
 
+// imageNames returns the manifest images the host runs. aws-cli copies
+// traefik's certificates to and from acmeStorage, if it is set.
+func imageNames(acmeStorage string) []string {
+	names := []string{"coder", "traefik"}
+	if acmeStorage != "" {
+		names = append(names, "aws-cli")
+	}
+	return names
+}
+
 // build fedora coreos ignition user data for the machine.
-func ignition(ctx *pulumi.Context, coderConfig *Config) (pulumi.StringOutput, error) {
+func ignition(ctx *pulumi.Context, coderConfig *Config, vols []*dreamlab.HostVolume, arch string) (pulumi.StringOutput, error) {
 	var out pulumi.StringOutput
+	zincati, err := coderConfig.Updates.ZincatiTOML()
+	if err != nil {
+		return out, fmt.Errorf("%s updates: %w", coderConfig.Hostname, err)
+	}
+	traefikYML, err := coderConfig.Traefik.StaticConfig()
+	if err != nil {
+		return out, fmt.Errorf("%s: %w", coderConfig.Hostname, err)
+	}
+	traefikDynamicYML, err := coderConfig.Traefik.DynamicConfig(coderConfig.DNS.Domain())
+	if err != nil {
+		return out, fmt.Errorf("%s: %w", coderConfig.Hostname, err)
+	}
+	acmeStorage := coderConfig.Traefik.ACME.StorageURL(coderConfig.Hostname)
+	images, err := dreamlab.ImageRefs(arch, coderConfig.AutoUpdate, imageNames(acmeStorage)...)
+	if err != nil {
+		return out, err
+	}
 	cfg := config.New(ctx, "")
 	out = pulumi.All(
 		cfg.GetSecret("googleOAuth2ClientID"),
@@ -230,13 +290,27 @@ func ignition(ctx *pulumi.Context, coderConfig *Config) (pulumi.StringOutput, er
 		cfg.Get("LSITClusterServer"),
 		cfg.GetSecret("LSITClusterToken"),
 		cfg.GetSecret("LSITOuterRimToken"),
+		dreamlab.VolumeIDs(vols),
 	).ApplyT(func(args []interface{}) (string, error) {
+		units := quadlets(coderConfig, images)
+		if err := quadlet.LintTraefik(units, coderConfig.Traefik.FileMiddlewares()); err != nil {
+			return "", fmt.Errorf("%s traefik labels: %w", coderConfig.Hostname, err)
+		}
 		vals := struct {
 			OIDCClientID      string
 			OIDCClientSecret  string
 			LSITClusterServer string
 			LSITClusterToken  string
 			LSITOuterRimToken string
+			Mounts            []dreamlab.Mount
+			Quadlets          []quadlet.Unit
+			ZincatiTOML       string
+			TraefikYML        string
+			TraefikDynamicYML string
+			TraefikAccessLog  bool
+			ACMEStorage       string
+			AWSCLIImage       string
+			AutoUpdate        *dreamlab.AutoUpdateConfig
 			Hostname          string
 			Domain            string
 		}{
@@ -245,15 +319,19 @@ func ignition(ctx *pulumi.Context, coderConfig *Config) (pulumi.StringOutput, er
 			LSITClusterServer: args[2].(string),
 			LSITClusterToken:  args[3].(string),
 			LSITOuterRimToken: args[4].(string),
+			Mounts:            dreamlab.Mounts(vols, args[5].([]string)),
+			Quadlets:          units,
+			ZincatiTOML:       zincati,
+			TraefikYML:        traefikYML,
+			TraefikDynamicYML: traefikDynamicYML,
+			TraefikAccessLog:  coderConfig.Traefik.AccessLog,
+			ACMEStorage:       acmeStorage,
+			AWSCLIImage:       images["aws-cli"],
+			AutoUpdate:        coderConfig.AutoUpdate,
 			Hostname:          coderConfig.Hostname,
 			Domain:            coderConfig.DNS.Domain(),
 		}
-		myFuncs := template.FuncMap{
-			"domainEscape": func(d string) string {
-				return strings.ReplaceAll(d, `.`, `\\.`)
-			},
-		}
-		tpl, err := template.New("butane").Funcs(myFuncs).Parse(string(butaneYML))
+		tpl, err := template.New("butane").Funcs(dreamlab.ButaneFuncs).Parse(string(butaneYML))
 		if err != nil {
 			return "", err
 		}
@@ -277,6 +355,9 @@ func ignition(ctx *pulumi.Context, coderConfig *Config) (pulumi.StringOutput, er
 			}
 			return "", err
 		}
+		if err := dreamlab.CheckIgnitionStorage(string(ign)); err != nil {
+			return "", err
+		}
 		return string(ign), nil
 	}).(pulumi.StringOutput)
 	return out, nil
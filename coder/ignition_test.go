@@ -0,0 +1,109 @@
+package coder
+
+import (
+	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/pulumitest"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// coder.env and the kubeconfigs are rendered from the stack config.
+// Filesystems are checked by dreamlab.CheckIgnitionStorage.
+func TestIgnitionCoderFiles(t *testing.T) {
+	stackConfig := map[string]string{
+		"googleOAuth2ClientID":     "client-id",
+		"googleOAuth2ClientSecret": "client-secret",
+		"LSITClusterServer":        "https://rancher.example.edu",
+		"LSITClusterToken":         "cluster-token",
+		"LSITOuterRimToken":        "outer-rim-token",
+	}
+	// the default stack doesn't auto-update, so the host runs pinned images
+	cfg := testHostConfig()
+	var ign string
+	err := pulumitest.Run(&pulumitest.Mocks{}, stackConfig, func(ctx *pulumi.Context) error {
+		dns, err := dreamlab.NewDNSZone(ctx)
+		if err != nil {
+			return err
+		}
+		cfg.DNS = dns
+		vols, err := dreamlab.NewHostVolumes(ctx, "coder", &dreamlab.HostVolumesArgs{
+			Hostname:         cfg.Hostname,
+			AvailabilityZone: pulumi.String("us-west-2a"),
+		})
+		if err != nil {
+			return err
+		}
+		out, err := ignition(ctx, cfg, vols, "x86_64")
+		if err != nil {
+			return err
+		}
+		out.ApplyT(func(s string) string {
+			ign = s
+			return s
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := dreamlab.IgnitionFiles(ign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, contents := range files {
+		if !strings.HasSuffix(path, ".container") {
+			continue
+		}
+		for _, line := range strings.Split(contents, "\n") {
+			if strings.HasPrefix(line, "Image=") && !strings.Contains(line, "@sha256:") {
+				t.Errorf("%s: %s isn't pinned by digest", path, line)
+			}
+		}
+	}
+	want := map[string][]string{
+		"/etc/coder/coder.env": {
+			"CODER_ACCESS_URL=https://coder.dreamlab.ucsb.edu\n",
+			"CODER_WILDCARD_ACCESS_URL=*.coder.dreamlab.ucsb.edu\n",
+			"CODER_OIDC_CLIENT_ID=client-id\n",
+			"CODER_OIDC_CLIENT_SECRET=client-secret\n",
+		},
+		"/etc/coder/kubeconfig/compute.yaml": {
+			`server: "https://rancher.example.edu"`,
+			`token: "cluster-token"`,
+		},
+		"/etc/coder/kubeconfig/outerrim.yaml": {
+			`token: "outer-rim-token"`,
+		},
+	}
+	for path, lines := range want {
+		contents, ok := files[path]
+		if !ok {
+			t.Errorf("no %s", path)
+			continue
+		}
+		for _, line := range lines {
+			if !strings.Contains(contents, line) {
+				t.Errorf("%s has no %q:\n%s", path, line, contents)
+			}
+		}
+	}
+}
+
+// The default stack config doesn't auto-update containers, so every image
+// the host runs must be pinned in the manifest (dreamlab images check -pin).
+func TestImageNamesPinned(t *testing.T) {
+	for _, arch := range []string{"x86_64", "aarch64"} {
+		refs, err := dreamlab.ImageRefs(arch, nil, imageNames("s3://bucket/acme")...)
+		if err != nil {
+			t.Errorf("%s: %v", arch, err)
+			continue
+		}
+		for name, ref := range refs {
+			if !strings.Contains(ref, "@sha256:") {
+				t.Errorf("%s: %s isn't pinned: %s", arch, name, ref)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package coder
+
+import (
+	"dreamlab/internal/dreamlab/iam"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// instance role policy for the coder server. The server provisions aws
+// workspaces with the aws-linux template: it may only launch allow-listed
+// instance types and AMIs into the worker subnets, instances must be
+// tagged Coder_Provisioned, and the only role and security group it can
+// give them are the workspace role and security group.
+func policy(coderConfig *Config, workspace *workspaceResources) pulumi.StringOutput {
+	vpcArn := coderConfig.VPC.Vpc.Arn
+	amis := make([]pulumi.StringInput, len(coderConfig.WorkspaceAMIs))
+	for i, ami := range coderConfig.WorkspaceAMIs {
+		amis[i] = pulumi.Sprintf("arn:aws:ec2:*::image/%s", ami)
+	}
+	stmts := coderConfig.DNS.ACMEStatements()
+	stmts = append(stmts, coderConfig.Traefik.Statements(coderConfig.Hostname)...)
+	stmts = append(stmts,
+		iam.Allow(
+			"ec2:GetDefaultCreditSpecification",
+			"ec2:DescribeIamInstanceProfileAssociations",
+			"ec2:DescribeTags",
+			"ec2:DescribeInstances",
+			"ec2:DescribeSubnets",
+			"ec2:DescribeInstanceStatus",
+			"ec2:DescribeInstanceTypes",
+			"ec2:DescribeInstanceCreditSpecifications",
+			"ec2:DescribeImages",
+			"ec2:DescribeVolumes",
+		).WithSid("CoderEC2Describe").OnAny(),
+		iam.Allow("ec2:RunInstances").
+			WithSid("CoderRunInstances").
+			On(pulumi.String("arn:aws:ec2:*:*:instance/*")).
+			When(
+				iam.StringEquals("aws:RequestTag/Coder_Provisioned", "true"),
+				iam.StringEquals("ec2:InstanceType", coderConfig.WorkspaceInstanceTypes...),
+			),
+		iam.Allow("ec2:RunInstances").
+			WithSid("CoderRunInstancesSubnet").
+			On(pulumi.String("arn:aws:ec2:*:*:subnet/*")).
+			When(iam.StringEquals("ec2:ResourceTag/dreamlab:service:coder", "workers")),
+		iam.Allow("ec2:RunInstances").
+			WithSid("CoderRunInstancesImage").
+			On(amis...),
+		iam.Allow("ec2:RunInstances").
+			WithSid("CoderRunInstancesNetwork").
+			On(pulumi.String("arn:aws:ec2:*:*:network-interface/*")).
+			When(iam.ArnLike("ec2:Vpc", vpcArn)),
+		iam.Allow("ec2:RunInstances").
+			WithSid("CoderRunInstancesSecurityGroup").
+			On(workspace.SecurityGroup.Arn),
+		iam.Allow("ec2:RunInstances").
+			WithSid("CoderRunInstancesVolume").
+			On(pulumi.String("arn:aws:ec2:*:*:volume/*")),
+		iam.Allow("ec2:CreateTags").
+			WithSid("CoderTagOnLaunch").
+			On(
+				pulumi.String("arn:aws:ec2:*:*:instance/*"),
+				pulumi.String("arn:aws:ec2:*:*:volume/*"),
+				pulumi.String("arn:aws:ec2:*:*:network-interface/*"),
+			).
+			When(iam.StringEquals("ec2:CreateAction", "RunInstances")),
+		iam.Allow(
+			"ec2:DescribeInstanceAttribute",
+			"ec2:UnmonitorInstances",
+			"ec2:TerminateInstances",
+			"ec2:StartInstances",
+			"ec2:StopInstances",
+			"ec2:DeleteTags",
+			"ec2:MonitorInstances",
+			"ec2:CreateTags",
+			"ec2:ModifyInstanceAttribute",
+			"ec2:ModifyInstanceCreditSpecification",
+		).WithSid("CoderEC2Resources").
+			On(pulumi.String("arn:aws:ec2:*:*:instance/*")).
+			When(iam.StringEquals("aws:ResourceTag/Coder_Provisioned", "true")),
+		iam.Allow("iam:PassRole").
+			WithSid("CoderPassWorkspaceRole").
+			On(workspace.Role.Arn).
+			When(iam.StringEquals("iam:PassedToService", "ec2.amazonaws.com")),
+		iam.Deny("iam:PassRole").
+			WithSid("CoderDenyPassOtherRoles").
+			NotOn(workspace.Role.Arn),
+	)
+	return iam.Document(stmts...)
+}
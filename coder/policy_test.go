@@ -0,0 +1,164 @@
+package coder
+
+import (
+	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/pulumitest"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// testPolicy is a parsed policy document
+type testPolicy struct {
+	Statement []testStatement
+}
+
+type testStatement struct {
+	Sid         string
+	Effect      string
+	Action      []string
+	Resource    []string
+	NotResource []string
+	Condition   map[string]map[string][]string
+}
+
+// renderPolicy returns the coder server's policy, with a VPC, DNS zone and
+// workspace resources from mocks.
+func renderPolicy(t *testing.T) *testPolicy {
+	t.Helper()
+	var policyJSON string
+	err := pulumitest.Run(&pulumitest.Mocks{}, nil, func(ctx *pulumi.Context) error {
+		vpc, err := dreamlab.NewAWSVPC(ctx)
+		if err != nil {
+			return err
+		}
+		dns, err := dreamlab.NewDNSZone(ctx)
+		if err != nil {
+			return err
+		}
+		cfg := &Config{
+			VPC:                    vpc,
+			DNS:                    dns,
+			Hostname:               "coder",
+			Traefik:                &dreamlab.TraefikConfig{ACME: &dreamlab.ACMEConfig{Email: "admin@ucsb.edu"}},
+			WorkspaceInstanceTypes: []string{"t3.large", "t3.xlarge"},
+			WorkspaceAMIs:          []string{"ami-0123456789abcdef0"},
+		}
+		workspace, err := newWorkspaceResources(ctx, "coder", cfg)
+		if err != nil {
+			return err
+		}
+		policy(cfg, workspace).ApplyT(func(s string) string {
+			policyJSON = s
+			return s
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p testPolicy
+	if err := json.Unmarshal([]byte(policyJSON), &p); err != nil {
+		t.Fatal(err)
+	}
+	return &p
+}
+
+// the ACME statements are tested in package dreamlab; the server must not
+// be granted every hosted zone
+func TestPolicyHostedZones(t *testing.T) {
+	for _, s := range renderPolicy(t).Statement {
+		for _, r := range s.Resource {
+			if strings.Contains(r, "hostedzone/*") {
+				t.Errorf("%s: grants every hosted zone (%s)", s.Sid, r)
+			}
+		}
+	}
+}
+
+// statement returns the policy's statement with the sid
+func (p *testPolicy) statement(t *testing.T, sid string) testStatement {
+	t.Helper()
+	i := slices.IndexFunc(p.Statement, func(s testStatement) bool { return s.Sid == sid })
+	if i < 0 {
+		t.Fatalf("no statement %s", sid)
+	}
+	return p.Statement[i]
+}
+
+// The server can only launch allow-listed workspaces into the worker
+// subnets, and only pass them the workspace role.
+func TestPolicyWorkspaces(t *testing.T) {
+	p := renderPolicy(t)
+
+	run := p.statement(t, "CoderRunInstances")
+	if want := []string{"arn:aws:ec2:*:*:instance/*"}; !slices.Equal(run.Resource, want) {
+		t.Errorf("CoderRunInstances resources are %v, want %v", run.Resource, want)
+	}
+	conds := run.Condition["StringEquals"]
+	if got, want := conds["aws:RequestTag/Coder_Provisioned"], []string{"true"}; !slices.Equal(got, want) {
+		t.Errorf("Coder_Provisioned request tag is %v, want %v", got, want)
+	}
+	if got, want := conds["ec2:InstanceType"], []string{"t3.large", "t3.xlarge"}; !slices.Equal(got, want) {
+		t.Errorf("instance types are %v, want %v", got, want)
+	}
+
+	subnet := p.statement(t, "CoderRunInstancesSubnet")
+	if got, want := subnet.Condition["StringEquals"]["ec2:ResourceTag/dreamlab:service:coder"], []string{"workers"}; !slices.Equal(got, want) {
+		t.Errorf("subnet tag is %v, want %v", got, want)
+	}
+
+	image := p.statement(t, "CoderRunInstancesImage")
+	if want := []string{"arn:aws:ec2:*::image/ami-0123456789abcdef0"}; !slices.Equal(image.Resource, want) {
+		t.Errorf("images are %v, want %v", image.Resource, want)
+	}
+
+	role := "arn:mock:coder-workspace-role"
+	pass := p.statement(t, "CoderPassWorkspaceRole")
+	if pass.Effect != "Allow" || !slices.Equal(pass.Resource, []string{role}) {
+		t.Errorf("CoderPassWorkspaceRole: %s on %v, want Allow on %s", pass.Effect, pass.Resource, role)
+	}
+	deny := p.statement(t, "CoderDenyPassOtherRoles")
+	if deny.Effect != "Deny" || !slices.Equal(deny.Action, []string{"iam:PassRole"}) {
+		t.Errorf("CoderDenyPassOtherRoles: %s %v, want Deny iam:PassRole", deny.Effect, deny.Action)
+	}
+	if len(deny.Resource) != 0 || !slices.Equal(deny.NotResource, []string{role}) {
+		t.Errorf("CoderDenyPassOtherRoles: resources %v, not resources %v, want not %s", deny.Resource, deny.NotResource, role)
+	}
+}
+
+func TestNewWorkspaceLists(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *Config
+		err  string
+	}{
+		{
+			name: "no instance types",
+			cfg:  &Config{WorkspaceAMIs: []string{"ami-0123456789abcdef0"}},
+			err:  "coder: no workspace instance types configured",
+		},
+		{
+			name: "no AMIs",
+			cfg:  &Config{WorkspaceInstanceTypes: []string{"t3.large"}},
+			err:  "coder: no workspace AMIs configured",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mocks := &pulumitest.Mocks{}
+			err := pulumitest.Run(mocks, nil, func(ctx *pulumi.Context) error {
+				return New(ctx, "coder", tt.cfg)
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error is %v, want %q", err, tt.err)
+			}
+			if n := len(mocks.Resources()); n != 0 {
+				t.Errorf("%d resources registered", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package coder
+
+import (
+	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/quadlet"
+	"fmt"
+	"regexp"
+)
+
+// quadlets returns the host's container, volume and network units.
+func quadlets(coderConfig *Config, images map[string]string) []quadlet.Unit {
+	host := coderConfig.Hostname + "." + coderConfig.DNS.Domain()
+	traefik := coderConfig.Traefik.Container(images["traefik"], coderConfig.Hostname, coderConfig.DNS.Domain())
+	traefik.Networks = []string{"host"}
+	coderConfig.AutoUpdate.Configure("traefik", traefik)
+
+	// coder serves the dashboard on host and workspace apps on subdomains.
+	// It authenticates users with OIDC itself.
+	router := quadlet.Router{
+		Name:         "coder-secure",
+		EntryPoints:  []string{dreamlab.TraefikEntryPoint},
+		Rule:         fmt.Sprintf("HostRegexp(`^(.+\\.)?%s$`)", regexp.QuoteMeta(host)),
+		CertResolver: dreamlab.TraefikCertResolver,
+		Domains:      []string{host, "*." + host},
+		Middlewares:  []string{dreamlab.ProfilePublic},
+		Public:       true,
+	}
+	coder := &quadlet.Container{
+		Name:          "coder",
+		Description:   "Coder Container",
+		ContainerName: "coder",
+		Image:         images["coder"],
+		Health: &quadlet.HealthCheck{
+			Cmd:         "wget -q -O /dev/null http://localhost:3000/healthz",
+			Interval:    "30s",
+			Retries:     3,
+			StartPeriod: "2m",
+		},
+		PublishPorts: []string{"3000"},
+		Volumes: []string{
+			"coder-home:/home",
+			"/etc/coder/kubeconfig:/etc/coder/kubeconfig",
+		},
+		EnvironmentFiles: []string{"/etc/coder/coder.env"},
+		Labels:           append([]quadlet.Label{quadlet.TraefikEnable}, router.Labels()...),
+	}
+	coderConfig.AutoUpdate.Configure("coder", coder)
+
+	units := []quadlet.Unit{traefik, coder, &quadlet.Volume{Name: "coder-home", VolumeName: "coder-home"}}
+	return append(units, coderConfig.Traefik.Volumes()...)
+}
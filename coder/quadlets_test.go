@@ -0,0 +1,107 @@
+package coder
+
+import (
+	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/pulumitest"
+	"dreamlab/internal/dreamlab/quadlet"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// testHostConfig is a coder host with the traefik dashboard
+func testHostConfig() *Config {
+	return &Config{
+		Hostname: "coder",
+		Traefik: &dreamlab.TraefikConfig{
+			ACME:        &dreamlab.ACMEConfig{Email: "admin@ucsb.edu"},
+			Dashboard:   true,
+			CampusCIDRs: []string{"128.111.0.0/16"},
+		},
+	}
+}
+
+// testQuadlets returns the host's units for the config, with a DNS zone
+// from mocks.
+func testQuadlets(t *testing.T, cfg *Config) []quadlet.Unit {
+	t.Helper()
+	err := pulumitest.Run(&pulumitest.Mocks{}, nil, func(ctx *pulumi.Context) error {
+		dns, err := dreamlab.NewDNSZone(ctx)
+		cfg.DNS = dns
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return quadlets(cfg, map[string]string{"traefik": "traefik:v3.4", "coder": "coder:v2.30.5"})
+}
+
+// coder's HostRegexp rule is quoted in the unit file, with its
+// backslashes escaped.
+func TestQuadletsHostRegexpLabel(t *testing.T) {
+	var coder string
+	for _, u := range testQuadlets(t, testHostConfig()) {
+		if u.FileName() == "coder.container" {
+			coder = u.String()
+		}
+	}
+	want := "Label=\"traefik.http.routers.coder-secure.rule=HostRegexp(`^(.+\\\\.)?coder\\\\.dreamlab\\\\.ucsb\\\\.edu$`)\"\n"
+	if !strings.Contains(coder, want) {
+		t.Errorf("coder.container =\n%s\nwant line %s", coder, want)
+	}
+}
+
+func TestQuadletsLintTraefik(t *testing.T) {
+	for _, dashboard := range []bool{false, true} {
+		cfg := testHostConfig()
+		cfg.Traefik.Dashboard = dashboard
+		units := testQuadlets(t, cfg)
+		if err := quadlet.LintTraefik(units, cfg.Traefik.FileMiddlewares()); err != nil {
+			t.Errorf("dashboard %v: %v", dashboard, err)
+		}
+	}
+}
+
+// coder's rule matches every subdomain of its host, including the
+// dashboard's: the dashboard router must have the higher priority.
+func TestQuadletsDashboardPriority(t *testing.T) {
+	routers := map[string]map[string]string{}
+	for _, u := range testQuadlets(t, testHostConfig()) {
+		labels, err := quadlet.ParseLabels(u.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range labels {
+			rest, ok := strings.CutPrefix(l.Key, "traefik.http.routers.")
+			if !ok {
+				continue
+			}
+			name, option, _ := strings.Cut(rest, ".")
+			if routers[name] == nil {
+				routers[name] = map[string]string{}
+			}
+			routers[name][option] = l.Value
+		}
+	}
+	// traefik's default priority is the rule's length
+	priority := func(name string) int {
+		if p, err := strconv.Atoi(routers[name]["priority"]); err == nil {
+			return p
+		}
+		return len(routers[name]["rule"])
+	}
+	dashboard, coder := routers["traefik-dashboard"], routers["coder-secure"]
+	if dashboard == nil || coder == nil {
+		t.Fatalf("routers = %v", routers)
+	}
+	rule := regexp.MustCompile("^HostRegexp\\(`(.*)`\\)$").FindStringSubmatch(coder["rule"])
+	if rule == nil || !regexp.MustCompile(rule[1]).MatchString("traefik.coder.dreamlab.ucsb.edu") {
+		t.Fatalf("coder rule %s doesn't match the dashboard's host", coder["rule"])
+	}
+	if priority("traefik-dashboard") <= priority("coder-secure") {
+		t.Errorf("dashboard priority %d isn't above coder's %d", priority("traefik-dashboard"), priority("coder-secure"))
+	}
+}
@@ -0,0 +1,88 @@
+package coder
+
+import (
+	"dreamlab/internal/dreamlab/iam"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	awsiam "github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// resources used by coder's aws workspaces
+type workspaceResources struct {
+	Role          *awsiam.Role
+	Profile       *awsiam.InstanceProfile
+	SecurityGroup *ec2.SecurityGroup
+}
+
+// create the role, instance profile and security group for aws workspaces
+// launched by the coder server. Workspaces can read the shared datasets
+// bucket and register with SSM, and nothing else. The profile and security
+// group are exported for the aws-linux template.
+func newWorkspaceResources(ctx *pulumi.Context, resource string, coderConfig *Config) (*workspaceResources, error) {
+	roleResource := resource + "-workspace-role"
+	role, err := awsiam.NewRole(ctx, roleResource, &awsiam.RoleArgs{
+		Name:             pulumi.String(roleResource),
+		AssumeRolePolicy: pulumi.String(policyEC2AssumeRole),
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = awsiam.NewRolePolicyAttachment(ctx, roleResource+"-ssm", &awsiam.RolePolicyAttachmentArgs{
+		Role:      role.Name,
+		PolicyArn: pulumi.String("arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if bucket := coderConfig.WorkspaceDatasetsBucket; bucket != "" {
+		bucketArn := pulumi.Sprintf("arn:aws:s3:::%s", bucket)
+		_, err = awsiam.NewRolePolicy(ctx, roleResource+"-policy", &awsiam.RolePolicyArgs{
+			Role: role.Name,
+			Policy: iam.Document(
+				iam.Allow("s3:ListBucket", "s3:GetBucketLocation").
+					WithSid("DatasetsList").
+					On(bucketArn),
+				iam.Allow("s3:GetObject").
+					WithSid("DatasetsRead").
+					On(pulumi.Sprintf("%s/*", bucketArn)),
+			),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	profileResource := resource + "-workspace-profile"
+	profile, err := awsiam.NewInstanceProfile(ctx, profileResource, &awsiam.InstanceProfileArgs{
+		Name: pulumi.String(profileResource),
+		Role: role.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// workspaces only make outbound connections: the coder agent dials
+	// the server.
+	sgResource := resource + "-workspace-sg"
+	sg, err := ec2.NewSecurityGroup(ctx, sgResource, &ec2.SecurityGroupArgs{
+		Name:  pulumi.String(sgResource),
+		VpcId: coderConfig.VPC.Vpc.ID(),
+		Egress: &ec2.SecurityGroupEgressArray{
+			&ec2.SecurityGroupEgressArgs{
+				FromPort:       pulumi.Int(0),
+				ToPort:         pulumi.Int(0),
+				Protocol:       pulumi.String("-1"),
+				CidrBlocks:     pulumi.StringArray{pulumi.String("0.0.0.0/0")},
+				Ipv6CidrBlocks: pulumi.StringArray{pulumi.String("::/0")},
+			},
+		}})
+	if err != nil {
+		return nil, err
+	}
+	ctx.Export(coderConfig.Hostname+"-workspaceInstanceProfile", profile.Name)
+	ctx.Export(coderConfig.Hostname+"-workspaceSecurityGroup", sg.ID())
+	return &workspaceResources{
+		Role:          role,
+		Profile:       profile,
+		SecurityGroup: sg,
+	}, nil
+}
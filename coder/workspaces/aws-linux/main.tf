@@ -165,6 +165,8 @@ resource "aws_instance" "dev" {
   availability_zone = "${local.aws_region}a"
   instance_type     = data.coder_parameter.instance_type.value
   subnet_id = tolist(data.aws_subnets.private.ids)[0]
+  iam_instance_profile   = var.instance_profile
+  vpc_security_group_ids = [var.security_group_id]
   user_data =  data.cloudinit_config.user_data.rendered
   root_block_device {
     volume_size = tonumber(data.coder_parameter.instance_disk.value)
@@ -0,0 +1,9 @@
+variable "instance_profile" {
+  # pulumi stack output: coder-workspaceInstanceProfile
+  default = "coder-workspace-profile"
+}
+
+variable "security_group_id" {
+  # pulumi stack output: coder-workspaceSecurityGroup
+  type = string
+}
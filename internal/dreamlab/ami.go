@@ -0,0 +1,47 @@
+package dreamlab
+
+import (
+	"dreamlab/internal/dreamlab/fcos"
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+const defaultFCOSStream = "stable"
+
+// InstanceAMI returns the AMI for a host's instance and options for the
+// instance resource. If ami is empty, the current Fedora CoreOS image for
+// the stream, region and architecture is used, and the instance ignores
+// later AMI changes: FCOS hosts update themselves in place, so a new
+// stream release shouldn't replace the instance. Use `dreamlab fcos-ami`
+// to pin an AMI. A pinned AMI must match arch (see InstanceArch).
+func InstanceAMI(ctx *pulumi.Context, ami, stream, arch string) (string, []pulumi.ResourceOption, error) {
+	if ami != "" {
+		img, err := ec2.LookupAmi(ctx, &ec2.LookupAmiArgs{
+			Filters: []ec2.GetAmiFilter{{Name: "image-id", Values: []string{ami}}},
+		})
+		if err != nil {
+			return "", nil, fmt.Errorf("looking up %s: %w", ami, err)
+		}
+		if amiArch := normalizeArch(img.Architecture); amiArch != arch {
+			return "", nil, fmt.Errorf("%s is an %s image but the instance type is %s", ami, amiArch, arch)
+		}
+		return ami, nil, nil
+	}
+	if stream == "" {
+		stream = defaultFCOSStream
+	}
+	region := config.Get(ctx, "aws:region")
+	meta, err := fcos.Fetch(ctx.Context(), stream)
+	if err != nil {
+		return "", nil, err
+	}
+	img, err := meta.AMI(region, arch)
+	if err != nil {
+		return "", nil, err
+	}
+	ctx.Log.Info(fmt.Sprintf("using fcos %s %s image %s (%s)", stream, arch, img.Image, img.Release), nil)
+	return img.Image, []pulumi.ResourceOption{pulumi.IgnoreChanges([]string{"ami"})}, nil
+}
@@ -0,0 +1,67 @@
+package dreamlab
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CPU architectures, named as in Fedora CoreOS stream metadata
+const (
+	ArchARM64 = "aarch64"
+	ArchX86   = "x86_64"
+)
+
+// instanceFamilyArch is the architecture of EC2 instance families, for
+// checking instance types without calling the EC2 API. Add families here
+// as hosts start using them.
+var instanceFamilyArch = map[string]string{
+	// graviton
+	"a1":  ArchARM64,
+	"c6g": ArchARM64, "c6gd": ArchARM64, "c6gn": ArchARM64,
+	"c7g": ArchARM64, "c7gd": ArchARM64, "c7gn": ArchARM64,
+	"c8g": ArchARM64,
+	"m6g": ArchARM64, "m6gd": ArchARM64,
+	"m7g": ArchARM64, "m7gd": ArchARM64,
+	"m8g": ArchARM64,
+	"r6g": ArchARM64, "r6gd": ArchARM64,
+	"r7g": ArchARM64, "r7gd": ArchARM64,
+	"r8g": ArchARM64,
+	"t4g": ArchARM64,
+	// intel and amd
+	"c5": ArchX86, "c5a": ArchX86, "c5d": ArchX86, "c5n": ArchX86,
+	"c6a": ArchX86, "c6i": ArchX86, "c6id": ArchX86,
+	"c7a": ArchX86, "c7i": ArchX86,
+	"m5": ArchX86, "m5a": ArchX86, "m5d": ArchX86,
+	"m6a": ArchX86, "m6i": ArchX86, "m6id": ArchX86,
+	"m7a": ArchX86, "m7i": ArchX86, "m7i-flex": ArchX86,
+	"r5": ArchX86, "r5a": ArchX86, "r5d": ArchX86,
+	"r6a": ArchX86, "r6i": ArchX86, "r6id": ArchX86,
+	"r7a": ArchX86, "r7i": ArchX86,
+	"t2": ArchX86,
+	"t3": ArchX86, "t3a": ArchX86,
+}
+
+// InstanceArch returns the CPU architecture of the EC2 instance type
+// (e.g., "m7g.medium").
+func InstanceArch(instanceType string) (string, error) {
+	family, _, ok := strings.Cut(instanceType, ".")
+	if !ok {
+		return "", fmt.Errorf("invalid instance type %q", instanceType)
+	}
+	arch, ok := instanceFamilyArch[family]
+	if !ok {
+		return "", fmt.Errorf("unknown architecture for instance type %q: add the %q family to instanceFamilyArch", instanceType, family)
+	}
+	return arch, nil
+}
+
+// normalizeArch converts EC2's architecture names to the ones used here.
+func normalizeArch(arch string) string {
+	switch arch {
+	case "arm64":
+		return ArchARM64
+	case "amd64", "x86_64_mac":
+		return ArchX86
+	}
+	return arch
+}
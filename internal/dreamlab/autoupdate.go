@@ -0,0 +1,65 @@
+package dreamlab
+
+import (
+	"dreamlab/internal/dreamlab/quadlet"
+	"fmt"
+	"slices"
+)
+
+const defaultAutoUpdateSchedule = "daily"
+
+// AutoUpdateConfig configures podman auto-update for a host's containers.
+// Auto-updated containers run their image by tag instead of digest; podman
+// pulls new images for the tag on the schedule and rolls back to the
+// previous image if the container doesn't become healthy.
+type AutoUpdateConfig struct {
+	// Services are the names of the containers (images) to auto-update
+	Services []string `json:"services"`
+	// Schedule is a systemd OnCalendar expression (default: daily)
+	Schedule string `json:"schedule"`
+}
+
+// Enabled returns true if the service is auto-updated.
+func (c *AutoUpdateConfig) Enabled(service string) bool {
+	return c != nil && slices.Contains(c.Services, service)
+}
+
+// OnCalendar returns the schedule for podman-auto-update.timer, or an empty
+// string if no services are auto-updated.
+func (c *AutoUpdateConfig) OnCalendar() string {
+	if c == nil || len(c.Services) == 0 {
+		return ""
+	}
+	if c.Schedule == "" {
+		return defaultAutoUpdateSchedule
+	}
+	return c.Schedule
+}
+
+// validate checks that the auto-updated services are among the host's
+// services.
+func (c *AutoUpdateConfig) validate(services []string) error {
+	if c == nil {
+		return nil
+	}
+	for _, s := range c.Services {
+		if !slices.Contains(services, s) {
+			return fmt.Errorf("auto-update: unknown service %q", s)
+		}
+	}
+	return nil
+}
+
+// Configure enables auto-update for the container if service is
+// auto-updated. Containers with a health check don't start until they are
+// healthy, so podman rolls back images that fail the check. Containers
+// without one are rolled back if they or their ExecStartPost probes fail.
+func (c *AutoUpdateConfig) Configure(service string, ctr *quadlet.Container) {
+	if !c.Enabled(service) {
+		return
+	}
+	ctr.AutoUpdate = "registry"
+	if ctr.Health != nil {
+		ctr.Notify = "healthy"
+	}
+}
@@ -1,6 +1,8 @@
 package dreamlab
 
 import (
+	"dreamlab/internal/dreamlab/iam"
+
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/route53"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
@@ -105,3 +107,27 @@ func NewDNSZone(ctx *pulumi.Context) (*DNS, error) {
 func (d DNS) Domain() string {
 	return d.domain
 }
+
+// ACMEStatements returns IAM policy statements that allow Traefik's route53
+// dns challenge provider to manage _acme-challenge TXT records in the zone
+// (and nothing else).
+func (d DNS) ACMEStatements() []*iam.Statement {
+	return []*iam.Statement{
+		iam.Allow("route53:ChangeResourceRecordSets").
+			WithSid("ACMEChallengeRecords").
+			On(d.Arn).
+			When(
+				iam.StringLike("route53:ChangeResourceRecordSetsNormalizedRecordNames", "_acme-challenge.*."+d.domain).ForAllValues(),
+				iam.StringEquals("route53:ChangeResourceRecordSetsRecordTypes", "TXT").ForAllValues(),
+			),
+		iam.Allow("route53:ListResourceRecordSets").
+			WithSid("ACMEChallengeZone").
+			On(d.Arn),
+		iam.Allow("route53:GetChange").
+			WithSid("ACMEChallengeChanges").
+			On(pulumi.String("arn:aws:route53:::change/*")),
+		iam.Allow("route53:ListHostedZonesByName").
+			WithSid("ACMEChallengeListZones").
+			OnAny(),
+	}
+}
@@ -0,0 +1,69 @@
+package dreamlab
+
+import (
+	"dreamlab/internal/dreamlab/iam"
+	"dreamlab/internal/dreamlab/pulumitest"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type testPolicy struct {
+	Statement []struct {
+		Sid       string
+		Action    []string
+		Resource  []string
+		Condition map[string]map[string][]string
+	}
+}
+
+func TestACMEStatements(t *testing.T) {
+	var policyJSON string
+	err := pulumitest.Run(&pulumitest.Mocks{}, nil, func(ctx *pulumi.Context) error {
+		dns, err := NewDNSZone(ctx)
+		if err != nil {
+			return err
+		}
+		iam.Document(dns.ACMEStatements()...).ApplyT(func(s string) string {
+			policyJSON = s
+			return s
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var policy testPolicy
+	if err := json.Unmarshal([]byte(policyJSON), &policy); err != nil {
+		t.Fatal(err)
+	}
+	changes := false
+	for _, s := range policy.Statement {
+		for _, r := range s.Resource {
+			if strings.Contains(r, "hostedzone/*") {
+				t.Errorf("%s: grants every hosted zone (%s)", s.Sid, r)
+			}
+		}
+		if !slices.Contains(s.Action, "route53:ChangeResourceRecordSets") {
+			continue
+		}
+		changes = true
+		if want := []string{"arn:mock:dreamlab_dns"}; !slices.Equal(s.Resource, want) {
+			t.Errorf("%s: resources are %v, want %v", s.Sid, s.Resource, want)
+		}
+		names := s.Condition["ForAllValues:StringLike"]["route53:ChangeResourceRecordSetsNormalizedRecordNames"]
+		if want := []string{"_acme-challenge.*." + domain}; !slices.Equal(names, want) {
+			t.Errorf("%s: record names are %v, want %v", s.Sid, names, want)
+		}
+		types := s.Condition["ForAllValues:StringEquals"]["route53:ChangeResourceRecordSetsRecordTypes"]
+		if want := []string{"TXT"}; !slices.Equal(types, want) {
+			t.Errorf("%s: record types are %v, want %v", s.Sid, types, want)
+		}
+	}
+	if !changes {
+		t.Error("no statement allows route53:ChangeResourceRecordSets")
+	}
+}
@@ -0,0 +1,137 @@
+package dreamlab
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/dlm"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ebs"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// HostTag is the tag key set on a host's persistent volumes (and copied to
+// their snapshots) with the host's name as the value.
+const HostTag = "dreamlab:host"
+
+const (
+	defaultBackupSchedule = "cron(0 10 * * ? *)" // daily, 2-3am pacific
+	defaultBackupRetain   = 14
+)
+
+const policyDLMAssumeRole = `{
+	"Version": "2012-10-17",
+	"Statement": [{
+		"Effect": "Allow",
+		"Action": "sts:AssumeRole",
+		"Principal": {"Service": "dlm.amazonaws.com"}
+	}]
+}`
+
+// BackupConfig configures EBS snapshots of a host's persistent volumes. It
+// is read from the stack's "backups" config object.
+type BackupConfig struct {
+	Enabled bool `json:"enabled"`
+	// Schedule is a DLM cron expression, e.g. "cron(0 10 * * ? *)".
+	Schedule string `json:"schedule"`
+	// Retain is the number of snapshots to keep for each volume.
+	Retain int `json:"retain"`
+}
+
+func (c *BackupConfig) schedule() string {
+	if c.Schedule == "" {
+		return defaultBackupSchedule
+	}
+	return c.Schedule
+}
+
+func (c *BackupConfig) retain() int {
+	if c.Retain == 0 {
+		return defaultBackupRetain
+	}
+	return c.Retain
+}
+
+func (c *BackupConfig) validate() error {
+	if c.Schedule != "" && !strings.HasPrefix(c.Schedule, "cron(") {
+		return fmt.Errorf("backup schedule %q is not a cron() expression", c.Schedule)
+	}
+	if c.Retain < 0 || c.Retain > 1000 {
+		return fmt.Errorf("backup retain must be between 1 and 1000, got %d", c.Retain)
+	}
+	return nil
+}
+
+// NewBackupPolicy creates a Data Lifecycle Manager policy that snapshots
+// volumes tagged with HostTag=hostname. It does nothing if backups are not
+// enabled.
+func NewBackupPolicy(ctx *pulumi.Context, resource string, hostname string, cfg *BackupConfig) error {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	if err := cfg.validate(); err != nil {
+		return fmt.Errorf("%s: %w", resource, err)
+	}
+	roleResource := resource + "-dlm-role"
+	role, err := iam.NewRole(ctx, roleResource, &iam.RoleArgs{
+		Name:             pulumi.String(roleResource),
+		AssumeRolePolicy: pulumi.String(policyDLMAssumeRole),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = iam.NewRolePolicyAttachment(ctx, roleResource+"-attach", &iam.RolePolicyAttachmentArgs{
+		Role:      role.Name,
+		PolicyArn: pulumi.String("arn:aws:iam::aws:policy/service-role/AWSDataLifecycleManagerServiceRole"),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = dlm.NewLifecyclePolicy(ctx, resource+"-backups", &dlm.LifecyclePolicyArgs{
+		Description:      pulumi.String("snapshots of " + hostname + " volumes"),
+		ExecutionRoleArn: role.Arn,
+		State:            pulumi.String("ENABLED"),
+		PolicyDetails: &dlm.LifecyclePolicyPolicyDetailsArgs{
+			ResourceTypes: pulumi.StringArray{pulumi.String("VOLUME")},
+			TargetTags: pulumi.StringMap{
+				HostTag: pulumi.String(hostname),
+			},
+			Schedules: dlm.LifecyclePolicyPolicyDetailsScheduleArray{
+				&dlm.LifecyclePolicyPolicyDetailsScheduleArgs{
+					Name:     pulumi.String(hostname + " snapshots"),
+					CopyTags: pulumi.Bool(true),
+					CreateRule: &dlm.LifecyclePolicyPolicyDetailsScheduleCreateRuleArgs{
+						CronExpression: pulumi.String(cfg.schedule()),
+					},
+					RetainRule: &dlm.LifecyclePolicyPolicyDetailsScheduleRetainRuleArgs{
+						Count: pulumi.Int(cfg.retain()),
+					},
+				},
+			},
+		},
+	})
+	return err
+}
+
+// CheckRestoreSnapshot looks up the snapshot a host's volume will be
+// restored from. It returns an error if the snapshot is not a completed
+// snapshot of the host's volumes or is larger than size GiB.
+func CheckRestoreSnapshot(ctx *pulumi.Context, hostname, snapshotID string, size int) error {
+	snap, err := ebs.LookupSnapshot(ctx, &ebs.LookupSnapshotArgs{
+		SnapshotIds: []string{snapshotID},
+		Owners:      []string{"self"},
+	})
+	if err != nil {
+		return fmt.Errorf("restore snapshot for %q: %w", hostname, err)
+	}
+	if snap.State != "completed" {
+		return fmt.Errorf("restore snapshot %s for %q is %s", snapshotID, hostname, snap.State)
+	}
+	if host := snap.Tags[HostTag]; host != hostname {
+		return fmt.Errorf("restore snapshot %s is tagged for host %q, not %q", snapshotID, host, hostname)
+	}
+	if snap.VolumeSize > size {
+		return fmt.Errorf("restore snapshot %s (%d GiB) is larger than the %q volume (%d GiB)", snapshotID, snap.VolumeSize, hostname, size)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package dreamlab
+
+import (
+	"strings"
+	"text/template"
+)
+
+// ButaneFuncs are template functions for hosts' butane.yml templates.
+var ButaneFuncs = template.FuncMap{
+	// indent all lines of s by n spaces, for multi-line values in yaml
+	// block scalars
+	"indent": func(n int, s string) string {
+		pad := strings.Repeat(" ", n)
+		lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+		for i, l := range lines {
+			if l != "" {
+				lines[i] = pad + l
+			}
+		}
+		return strings.Join(lines, "\n")
+	},
+}
@@ -0,0 +1,112 @@
+// Package fcos reads Fedora CoreOS stream metadata, which lists the
+// current release and cloud images for each architecture in a stream.
+package fcos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+)
+
+const streamURL = "https://builds.coreos.fedoraproject.org/streams/%s.json"
+
+// Stream is the metadata for a Fedora CoreOS stream (e.g., stable.json).
+type Stream struct {
+	Stream   string `json:"stream"`
+	Metadata struct {
+		LastModified string `json:"last-modified"`
+	} `json:"metadata"`
+	Architectures map[string]Arch `json:"architectures"`
+}
+
+// Arch is the stream metadata for an architecture
+type Arch struct {
+	Images struct {
+		AWS *struct {
+			Regions map[string]Image `json:"regions"`
+		} `json:"aws"`
+	} `json:"images"`
+}
+
+// Image is a release's cloud image in a region.
+type Image struct {
+	Release string `json:"release"`
+	Image   string `json:"image"` // AMI ID for aws
+}
+
+// StreamURL returns the metadata URL for the stream
+func StreamURL(stream string) string {
+	return fmt.Sprintf(streamURL, stream)
+}
+
+// Parse reads stream metadata json from r.
+func Parse(r io.Reader) (*Stream, error) {
+	var s Stream
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("parsing fcos stream metadata: %w", err)
+	}
+	if s.Stream == "" || len(s.Architectures) == 0 {
+		return nil, fmt.Errorf("parsing fcos stream metadata: missing stream or architectures")
+	}
+	return &s, nil
+}
+
+// ParseFile reads stream metadata from a local file
+func ParseFile(name string) (*Stream, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Fetch downloads the current metadata for the stream
+func Fetch(ctx context.Context, stream string) (*Stream, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, StreamURL(stream), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching fcos %s stream: %w", stream, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching fcos %s stream: %s", stream, resp.Status)
+	}
+	return Parse(resp.Body)
+}
+
+// AMI returns the stream's AWS image for the region and architecture
+// (aarch64 or x86_64).
+func (s *Stream) AMI(region, arch string) (Image, error) {
+	a, ok := s.Architectures[arch]
+	if !ok {
+		return Image{}, fmt.Errorf("fcos %s stream: no architecture %q (have %v)", s.Stream, arch, keys(s.Architectures))
+	}
+	if a.Images.AWS == nil {
+		return Image{}, fmt.Errorf("fcos %s stream: no aws images for %s", s.Stream, arch)
+	}
+	img, ok := a.Images.AWS.Regions[region]
+	if !ok || img.Image == "" {
+		return Image{}, fmt.Errorf("fcos %s stream: no %s aws image in region %q", s.Stream, arch, region)
+	}
+	return img, nil
+}
+
+func keys[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
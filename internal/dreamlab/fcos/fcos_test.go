@@ -0,0 +1,65 @@
+package fcos
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{"valid", `{"stream":"stable","architectures":{"x86_64":{}}}`, false},
+		{"no stream", `{"architectures":{"x86_64":{}}}`, true},
+		{"no architectures", `{"stream":"stable"}`, true},
+		{"invalid json", `{"stream":`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.json))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAMI(t *testing.T) {
+	s, err := ParseFile("testdata/stable.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Stream != "stable" || s.Metadata.LastModified != "2026-09-29T17:53:08Z" {
+		t.Errorf("stream = %q, last-modified = %q", s.Stream, s.Metadata.LastModified)
+	}
+	tests := []struct {
+		region, arch string
+		want         string
+		wantErr      string
+	}{
+		{"us-west-2", "x86_64", "ami-0fedcba9876543210", ""},
+		{"us-west-2", "aarch64", "ami-0f1e2d3c4b5a69788", ""},
+		{"us-west-2", "s390x", "", `no architecture "s390x" (have [aarch64 ppc64le x86_64])`},
+		{"us-west-2", "ppc64le", "", "no aws images for ppc64le"},
+		{"eu-north-1", "x86_64", "", `no x86_64 aws image in region "eu-north-1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arch+"/"+tt.region, func(t *testing.T) {
+			img, err := s.AMI(tt.region, tt.arch)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("AMI() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img.Image != tt.want || img.Release != "42.20250914.3.0" {
+				t.Errorf("AMI() = %+v, want %s", img, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+// Package iam builds IAM policy documents from typed statements. Resources
+// and condition values are pulumi inputs, so statements can reference the
+// ARNs and IDs of other resources in the stack.
+package iam
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const policyVersion = "2012-10-17"
+
+type Effect string
+
+const (
+	EffectAllow Effect = "Allow"
+	EffectDeny  Effect = "Deny"
+)
+
+// Statement is a policy statement. Use Allow or Deny to create one.
+type Statement struct {
+	Sid          string
+	Effect       Effect
+	Actions      []string
+	Resources    []pulumi.StringInput
+	NotResources []pulumi.StringInput
+	Conditions   []Condition
+	// principals of resource policies, e.g. bucket policies
+	Principals []Principal
+
+	// reason the statement is allowed to use "*" with mutating actions
+	wildcardReason string
+}
+
+// Allow returns a new statement allowing the actions
+func Allow(actions ...string) *Statement {
+	return &Statement{Effect: EffectAllow, Actions: actions}
+}
+
+// Deny returns a new statement denying the actions
+func Deny(actions ...string) *Statement {
+	return &Statement{Effect: EffectDeny, Actions: actions}
+}
+
+// WithSid sets the statement's Sid
+func (s *Statement) WithSid(sid string) *Statement {
+	s.Sid = sid
+	return s
+}
+
+// On adds resources to the statement.
+func (s *Statement) On(resources ...pulumi.StringInput) *Statement {
+	s.Resources = append(s.Resources, resources...)
+	return s
+}
+
+// OnAny sets the statement's resource to "*".
+func (s *Statement) OnAny() *Statement {
+	return s.On(pulumi.String("*"))
+}
+
+// NotOn adds resources to the statement's NotResource list.
+func (s *Statement) NotOn(resources ...pulumi.StringInput) *Statement {
+	s.NotResources = append(s.NotResources, resources...)
+	return s
+}
+
+// To adds a principal to the statement, for resource policies.
+func (s *Statement) To(principalType string, ids ...pulumi.StringInput) *Statement {
+	s.Principals = append(s.Principals, Principal{Type: principalType, IDs: ids})
+	return s
+}
+
+// ToAnyone sets the statement's principal to any AWS principal, including
+// anonymous users.
+func (s *Statement) ToAnyone() *Statement {
+	return s.To("AWS", pulumi.String("*"))
+}
+
+// When adds conditions to the statement.
+func (s *Statement) When(conds ...Condition) *Statement {
+	s.Conditions = append(s.Conditions, conds...)
+	return s
+}
+
+// AllowWildcard marks the statement as intentionally granting mutating
+// actions on "*", which Document would otherwise reject. Document's errors
+// for the statement include the reason, so whoever changes it sees why the
+// wildcard was allowed. Validate checks policy json, which has no reasons,
+// and still rejects the statement.
+func (s *Statement) AllowWildcard(reason string) *Statement {
+	s.wildcardReason = reason
+	return s
+}
+
+// Principal is a principal type ("AWS", "Service", ...) and its IDs.
+type Principal struct {
+	Type string
+	IDs  []pulumi.StringInput
+}
+
+// Condition is a single condition operator/key/values entry.
+type Condition struct {
+	Operator string
+	Key      string
+	Values   []pulumi.StringInput
+}
+
+// NewCondition returns a condition with the given operator
+func NewCondition(operator, key string, values ...pulumi.StringInput) Condition {
+	return Condition{Operator: operator, Key: key, Values: values}
+}
+
+func StringEquals(key string, values ...string) Condition {
+	return NewCondition("StringEquals", key, pulumi.ToStringArray(values)...)
+}
+
+func StringNotEquals(key string, values ...string) Condition {
+	return NewCondition("StringNotEquals", key, pulumi.ToStringArray(values)...)
+}
+
+func StringLike(key string, values ...string) Condition {
+	return NewCondition("StringLike", key, pulumi.ToStringArray(values)...)
+}
+
+func ArnLike(key string, values ...pulumi.StringInput) Condition {
+	return NewCondition("ArnLike", key, values...)
+}
+
+func Bool(key string, value bool) Condition {
+	return NewCondition("Bool", key, pulumi.Sprintf("%t", value))
+}
+
+// ForAllValues returns a copy of the condition with the ForAllValues set
+// operator qualifier.
+func (c Condition) ForAllValues() Condition {
+	c.Operator = "ForAllValues:" + c.Operator
+	return c
+}
+
+// ForAnyValue returns a copy of the condition with the ForAnyValue set
+// operator qualifier.
+func (c Condition) ForAnyValue() Condition {
+	c.Operator = "ForAnyValue:" + c.Operator
+	return c
+}
+
+// Document resolves the statements' inputs and returns the policy document
+// as json. The document is validated before it is returned. Statements are
+// also validated with the inputs known now, e.g. pulumi.String resources,
+// so that previews fail on invalid statements even if the ARNs of new
+// resources aren't known yet.
+func Document(stmts ...*Statement) pulumi.StringOutput {
+	lists := inputLists(stmts)
+	known := make([][]string, len(lists))
+	for i, list := range lists {
+		for _, in := range list {
+			known[i] = append(known[i], knownValue(in))
+		}
+	}
+	if _, err := newDocument(stmts, known); err != nil {
+		// a known output, so the error isn't skipped in previews
+		return pulumi.String("").ToStringOutput().ApplyT(func(string) (string, error) {
+			return "", err
+		}).(pulumi.StringOutput)
+	}
+	inputs := make([]any, len(lists))
+	for i, list := range lists {
+		inputs[i] = list
+	}
+	return pulumi.All(inputs...).ApplyT(func(args []any) (string, error) {
+		vals := make([][]string, len(args))
+		for i, arg := range args {
+			vals[i] = arg.([]string)
+		}
+		doc, err := newDocument(stmts, vals)
+		if err != nil {
+			return "", err
+		}
+		b, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}).(pulumi.StringOutput)
+}
+
+// unknownValue stands in for inputs that aren't known before an update. It
+// isn't "*", so it doesn't grant anything.
+const unknownValue = "<unknown>"
+
+func knownValue(in pulumi.StringInput) string {
+	if s, ok := in.(pulumi.String); ok {
+		return string(s)
+	}
+	return unknownValue
+}
+
+// inputLists returns the statements' inputs in the order newDocument reads
+// their values: each statement's resources, not resources, principals and
+// conditions.
+func inputLists(stmts []*Statement) []pulumi.StringArray {
+	var lists []pulumi.StringArray
+	for _, s := range stmts {
+		lists = append(lists, s.Resources, s.NotResources)
+		for _, p := range s.Principals {
+			lists = append(lists, p.IDs)
+		}
+		for _, c := range s.Conditions {
+			lists = append(lists, c.Values)
+		}
+	}
+	return lists
+}
+
+// newDocument returns the validated document of the statements given the
+// values of their inputs (see inputLists).
+func newDocument(stmts []*Statement, vals [][]string) (document, error) {
+	doc := document{Version: policyVersion}
+	i := 0
+	next := func() []string {
+		v := vals[i]
+		i++
+		return v
+	}
+	for _, s := range stmts {
+		st := statement{
+			Sid:            s.Sid,
+			Effect:         string(s.Effect),
+			Action:         s.Actions,
+			Resource:       next(),
+			NotResource:    next(),
+			wildcardReason: s.wildcardReason,
+		}
+		for _, p := range s.Principals {
+			if st.Principal == nil {
+				st.Principal = map[string]stringList{}
+			}
+			st.Principal[p.Type] = append(st.Principal[p.Type], next()...)
+		}
+		for _, c := range s.Conditions {
+			if st.Condition == nil {
+				st.Condition = map[string]map[string]stringList{}
+			}
+			if st.Condition[c.Operator] == nil {
+				st.Condition[c.Operator] = map[string]stringList{}
+			}
+			if _, exists := st.Condition[c.Operator][c.Key]; exists {
+				return doc, fmt.Errorf("statement %q: duplicate condition %s %s", s.Sid, c.Operator, c.Key)
+			}
+			st.Condition[c.Operator][c.Key] = next()
+		}
+		doc.Statement = append(doc.Statement, st)
+	}
+	return doc, doc.validate()
+}
+
+type document struct {
+	Version   string      `json:"Version"`
+	Statement []statement `json:"Statement"`
+}
+
+type statement struct {
+	Sid         string                           `json:"Sid,omitempty"`
+	Effect      string                           `json:"Effect"`
+	Action      stringList                       `json:"Action"`
+	Resource    stringList                       `json:"Resource,omitempty"`
+	NotResource stringList                       `json:"NotResource,omitempty"`
+	Principal   map[string]stringList            `json:"Principal,omitempty"`
+	Condition   map[string]map[string]stringList `json:"Condition,omitempty"`
+
+	wildcardReason string
+}
+
+// stringList is a json value that may be a single string or an array of
+// strings, as in IAM policy documents.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*l = stringList{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(b, &multi); err != nil {
+		return err
+	}
+	*l = multi
+	return nil
+}
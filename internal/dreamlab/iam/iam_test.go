@@ -0,0 +1,132 @@
+package iam
+
+import (
+	"dreamlab/internal/dreamlab/pulumitest"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	awsiam "github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// runDocument returns the json of a policy resource with the statements'
+// Document, or the program's error.
+func runDocument(t *testing.T, stmts func() []*Statement) (string, error) {
+	t.Helper()
+	mocks := &pulumitest.Mocks{}
+	err := pulumitest.Run(mocks, nil, func(ctx *pulumi.Context) error {
+		_, err := awsiam.NewPolicy(ctx, "policy", &awsiam.PolicyArgs{
+			Policy: Document(stmts()...),
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	r := mocks.Find("policy")
+	if r == nil {
+		t.Fatal("policy wasn't registered")
+	}
+	return r.Inputs["policy"].StringValue(), nil
+}
+
+// unknown returns a string output that isn't known, like the ARN of a
+// resource that doesn't exist yet in a preview
+func unknown() pulumi.StringOutput {
+	return pulumi.UnsafeUnknownOutput(nil).ApplyT(func(any) string { return "" }).(pulumi.StringOutput)
+}
+
+func TestDocument(t *testing.T) {
+	policyJSON, err := runDocument(t, func() []*Statement {
+		return []*Statement{
+			Allow("s3:GetObject").
+				WithSid("Read").
+				On(pulumi.String("arn:aws:s3:::b/*")).
+				When(StringLike("s3:prefix", "ocfl/*")),
+			Allow("s3:GetObject").
+				WithSid("Anyone").
+				ToAnyone().
+				On(pulumi.Sprintf("arn:aws:s3:::%s/*", "b")),
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc document
+	if err := json.Unmarshal([]byte(policyJSON), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Statement) != 2 {
+		t.Fatalf("%d statements, want 2", len(doc.Statement))
+	}
+	if got := doc.Statement[0].Condition["StringLike"]["s3:prefix"]; len(got) != 1 || got[0] != "ocfl/*" {
+		t.Errorf("condition is %v", got)
+	}
+	if got := doc.Statement[1].Principal["AWS"]; len(got) != 1 || got[0] != "*" {
+		t.Errorf("principal is %v", got)
+	}
+}
+
+// Invalid statements fail even if resources aren't known, as in previews
+// of stacks with new resources.
+func TestDocumentUnknownInputs(t *testing.T) {
+	tests := []struct {
+		name  string
+		stmts func() []*Statement
+		err   string
+	}{
+		{
+			name: "unknown action",
+			stmts: func() []*Statement {
+				return []*Statement{Allow("s3:GetObjekt").On(unknown())}
+			},
+			err: `unknown action "s3:GetObjekt"`,
+		},
+		{
+			name: "write on any resource",
+			stmts: func() []*Statement {
+				return []*Statement{Allow("s3:PutObject").OnAny().When(
+					NewCondition("StringEquals", "aws:SourceArn", unknown()),
+				)}
+			},
+			err: `mutating action "s3:PutObject" on resource "*"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runDocument(t, tt.stmts)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error is %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDocumentDuplicateCondition(t *testing.T) {
+	_, err := runDocument(t, func() []*Statement {
+		return []*Statement{Allow("s3:GetObject").OnAny().When(
+			StringLike("s3:prefix", "a/*"),
+			StringLike("s3:prefix", "b/*"),
+		)}
+	})
+	if err == nil || !strings.Contains(err.Error(), "duplicate condition") {
+		t.Errorf("error is %v, want duplicate condition", err)
+	}
+}
+
+// A statement allowed to write on "*" names the reason in its other errors.
+func TestDocumentWildcardReason(t *testing.T) {
+	_, err := runDocument(t, func() []*Statement {
+		return []*Statement{
+			Allow("ec2:CreateTags", "ec2:CreateTagz").OnAny().AllowWildcard("instances are tagged at launch"),
+		}
+	})
+	want := `statement #0: unknown action "ec2:CreateTagz" ("*" is allowed: instances are tagged at launch)`
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("error is %v, want %q", err, want)
+	}
+	if strings.Contains(err.Error(), `"ec2:CreateTags" on resource`) {
+		t.Errorf("wildcard isn't allowed: %v", err)
+	}
+}
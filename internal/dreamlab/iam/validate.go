@@ -0,0 +1,125 @@
+package iam
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// actions.txt lists known IAM actions and their access level, one per
+// line: "service:Action Level". Levels are List, Read, Write, Tagging and
+// Permissions. Add actions here as policies start using them.
+//
+//go:embed actions.txt
+var actionsTxt string
+
+// access levels that change resources
+var mutatingLevels = []string{"Write", "Tagging", "Permissions"}
+
+var loadActions = sync.OnceValues(func() (map[string]string, error) {
+	actions := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(actionsTxt))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, level, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid line in actions.txt: %q", line)
+		}
+		actions[strings.ToLower(name)] = strings.TrimSpace(level)
+	}
+	return actions, scanner.Err()
+})
+
+// Validate checks a json IAM policy document: every action must match the
+// bundled action list and Allow statements may not grant mutating actions
+// on Resource "*" or to any principal ("*").
+func Validate(policyJSON string) error {
+	var doc document
+	if err := json.Unmarshal([]byte(policyJSON), &doc); err != nil {
+		return fmt.Errorf("parsing policy: %w", err)
+	}
+	return doc.validate()
+}
+
+func (doc document) validate() error {
+	known, err := loadActions()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, st := range doc.Statement {
+		name := st.Sid
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		for _, err := range st.validate(name, known) {
+			if st.wildcardReason != "" {
+				err = fmt.Errorf("%w (\"*\" is allowed: %s)", err, st.wildcardReason)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate returns the statement's errors; name identifies it in them.
+func (st statement) validate(name string, known map[string]string) []error {
+	var errs []error
+	if st.Effect != string(EffectAllow) && st.Effect != string(EffectDeny) {
+		errs = append(errs, fmt.Errorf("statement %s: invalid effect %q", name, st.Effect))
+	}
+	if len(st.Action) == 0 {
+		errs = append(errs, fmt.Errorf("statement %s: no actions", name))
+	}
+	if len(st.Resource) == 0 && len(st.NotResource) == 0 {
+		errs = append(errs, fmt.Errorf("statement %s: no resources", name))
+	}
+	wildcard := slices.Contains(st.Resource, "*")
+	anyone := slices.Contains(st.Principal["AWS"], "*")
+	for _, action := range st.Action {
+		levels := actionLevels(known, action)
+		if len(levels) == 0 {
+			errs = append(errs, fmt.Errorf("statement %s: unknown action %q", name, action))
+			continue
+		}
+		if st.Effect != string(EffectAllow) {
+			continue
+		}
+		mutating := slices.ContainsFunc(levels, func(l string) bool { return slices.Contains(mutatingLevels, l) })
+		if mutating && anyone {
+			errs = append(errs, fmt.Errorf("statement %s: mutating action %q for any principal", name, action))
+		}
+		if mutating && wildcard && st.wildcardReason == "" {
+			errs = append(errs, fmt.Errorf("statement %s: mutating action %q on resource \"*\"", name, action))
+		}
+	}
+	return errs
+}
+
+// actionLevels returns the access levels of known actions matching action,
+// which may include wildcards.
+func actionLevels(known map[string]string, action string) []string {
+	action = strings.ToLower(action)
+	if level, ok := known[action]; ok {
+		return []string{level}
+	}
+	if !strings.ContainsAny(action, "*?") {
+		return nil
+	}
+	var levels []string
+	for name, level := range known {
+		if ok, _ := path.Match(action, name); ok && !slices.Contains(levels, level) {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
@@ -0,0 +1,83 @@
+package iam
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		err    string // substring of the error, "" if valid
+	}{
+		{
+			name:   "read on any resource",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}`,
+		},
+		{
+			name:   "write on a resource",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": ["s3:PutObject"], "Resource": ["arn:aws:s3:::b/*"]}]}`,
+		},
+		{
+			name:   "action wildcard matching reads",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject*", "Resource": "*"}]}`,
+		},
+		{
+			name:   "deny write on any resource",
+			policy: `{"Statement": [{"Effect": "Deny", "Action": "s3:PutObject", "Resource": "*"}]}`,
+		},
+		{
+			name:   "unknown action",
+			policy: `{"Statement": [{"Sid": "X", "Effect": "Allow", "Action": "s3:GetObjekt", "Resource": "*"}]}`,
+			err:    `statement X: unknown action "s3:GetObjekt"`,
+		},
+		{
+			name:   "unknown action wildcard",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "s4:*", "Resource": "*"}]}`,
+			err:    `unknown action "s4:*"`,
+		},
+		{
+			name:   "write on any resource",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "s3:PutObject", "Resource": "*"}]}`,
+			err:    `mutating action "s3:PutObject" on resource "*"`,
+		},
+		{
+			name:   "action wildcard matching writes on any resource",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}]}`,
+			err:    `mutating action "s3:*" on resource "*"`,
+		},
+		{
+			name:   "write for anyone",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "s3:PutObject", "Resource": "arn:aws:s3:::b/*", "Principal": {"AWS": "*"}}]}`,
+			err:    `mutating action "s3:PutObject" for any principal`,
+		},
+		{
+			name:   "read for anyone",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::b/*", "Principal": {"AWS": "*"}}]}`,
+		},
+		{
+			name:   "invalid effect",
+			policy: `{"Statement": [{"Effect": "allow", "Action": "s3:GetObject", "Resource": "*"}]}`,
+			err:    `invalid effect "allow"`,
+		},
+		{
+			name:   "no resources",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject"}]}`,
+			err:    "no resources",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.policy)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.err != "" && err == nil:
+				t.Errorf("no error, want %q", tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Errorf("error is %q, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package dreamlab
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vincent-petithory/dataurl"
+)
+
+// EBSDevicePath returns the stable device path for an attached EBS volume
+// on a Nitro instance. Attached volumes are NVMe devices whose enumeration
+// order (nvme1n1, nvme2n1, ...) isn't guaranteed, but udev links them by
+// serial number, which is the volume ID without the dash.
+func EBSDevicePath(volumeID string) string {
+	return "/dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_" + strings.ReplaceAll(volumeID, "-", "")
+}
+
+// CheckIgnitionStorage returns an error if the ignition config's
+// filesystems could destroy data on persistent volumes: filesystems must
+// not set wipeFilesystem and must use stable device paths.
+func CheckIgnitionStorage(ign string) error {
+	var cfg struct {
+		Storage struct {
+			Filesystems []struct {
+				Device         string `json:"device"`
+				Label          string `json:"label"`
+				WipeFilesystem *bool  `json:"wipeFilesystem"`
+			} `json:"filesystems"`
+		} `json:"storage"`
+	}
+	if err := json.Unmarshal([]byte(ign), &cfg); err != nil {
+		return fmt.Errorf("parsing ignition config: %w", err)
+	}
+	var errs []error
+	for _, fs := range cfg.Storage.Filesystems {
+		if fs.WipeFilesystem != nil && *fs.WipeFilesystem {
+			errs = append(errs, fmt.Errorf("filesystem on %s (label %q) sets wipeFilesystem", fs.Device, fs.Label))
+		}
+		if !strings.HasPrefix(fs.Device, "/dev/disk/") {
+			errs = append(errs, fmt.Errorf("filesystem device %s is not a stable /dev/disk path", fs.Device))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IgnitionFiles returns the contents of the ignition config's files by
+// path. Files must have inline (data url) contents, as butane renders them.
+func IgnitionFiles(ign string) (map[string]string, error) {
+	var cfg struct {
+		Storage struct {
+			Files []struct {
+				Path     string `json:"path"`
+				Contents struct {
+					Compression string `json:"compression"`
+					Source      string `json:"source"`
+				} `json:"contents"`
+			} `json:"files"`
+		} `json:"storage"`
+	}
+	if err := json.Unmarshal([]byte(ign), &cfg); err != nil {
+		return nil, fmt.Errorf("parsing ignition config: %w", err)
+	}
+	files := map[string]string{}
+	for _, f := range cfg.Storage.Files {
+		data, err := dataurl.DecodeString(f.Contents.Source)
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %w", f.Path, err)
+		}
+		contents := data.Data
+		switch f.Contents.Compression {
+		case "":
+		case "gzip":
+			r, err := gzip.NewReader(bytes.NewReader(contents))
+			if err != nil {
+				return nil, fmt.Errorf("file %s: %w", f.Path, err)
+			}
+			if contents, err = io.ReadAll(r); err != nil {
+				return nil, fmt.Errorf("file %s: %w", f.Path, err)
+			}
+		default:
+			return nil, fmt.Errorf("file %s: unknown compression %q", f.Path, f.Contents.Compression)
+		}
+		files[f.Path] = string(contents)
+	}
+	return files, nil
+}
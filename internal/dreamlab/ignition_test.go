@@ -0,0 +1,55 @@
+package dreamlab
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestCheckIgnitionStorage(t *testing.T) {
+	tests := []struct {
+		name    string
+		ign     string
+		wantErr bool
+	}{
+		{"keeps filesystem", `{"storage":{"filesystems":[{"device":"/dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_vol1","format":"xfs","wipeFilesystem":false}]}}`, false},
+		{"no filesystems", `{"storage":{}}`, false},
+		{"wipes filesystem", `{"storage":{"filesystems":[{"device":"/dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_vol1","label":"var","wipeFilesystem":true}]}}`, true},
+		{"kernel device name", `{"storage":{"filesystems":[{"device":"/dev/nvme1n1","wipeFilesystem":false}]}}`, true},
+		{"invalid json", `{`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckIgnitionStorage(tt.ign)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckIgnitionStorage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIgnitionFiles(t *testing.T) {
+	ign := `{"storage":{"files":[
+		{"path":"/etc/plain.env","contents":{"compression":"","source":"data:,A%3D1%0AB%3Ds3%3A%2F%2Fbucket%0A"}},
+		{"path":"/etc/gzip.env","contents":{"compression":"gzip","source":"data:;base64,H4sIAAAAAAACA/N2jbQtS8wpTeUCAMvatDAKAAAA"}}
+	]}}`
+	files, err := IgnitionFiles(ign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"/etc/plain.env": "A=1\nB=s3://bucket\n",
+		"/etc/gzip.env":  "KEY=value\n",
+	}
+	if !maps.Equal(files, want) {
+		t.Errorf("IgnitionFiles() = %q, want %q", files, want)
+	}
+	for _, bad := range []string{
+		`{`,
+		`{"storage":{"files":[{"path":"/x","contents":{"source":"https://example.com/x"}}]}}`,
+		`{"storage":{"files":[{"path":"/x","contents":{"compression":"xz","source":"data:,x"}}]}}`,
+	} {
+		if _, err := IgnitionFiles(bad); err == nil {
+			t.Errorf("IgnitionFiles(%s) didn't fail", bad)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package dreamlab
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ImageManifestFile is the path of the image manifest, relative to the
+// repository root.
+const ImageManifestFile = "internal/dreamlab/images.json"
+
+//go:embed images.json
+var imagesJSON []byte
+
+// Image is a container image in the manifest (images.json). Hosts run
+// images by digest: after changing a tag, update its digest with
+// `dreamlab images check -pin`.
+type Image struct {
+	Name   string `json:"name"`
+	Repo   string `json:"repo"`
+	Tag    string `json:"tag"`
+	Digest string `json:"digest"` // sha256:... of the tag's index or manifest
+	// Arches are the architectures the image is published for.
+	Arches []string `json:"arches"`
+}
+
+// Ref returns the image reference to run on the architecture:
+// repo:tag@digest, or repo:tag if the image isn't pinned.
+func (img Image) Ref(arch string) (string, error) {
+	if !slices.Contains(img.Arches, arch) {
+		return "", fmt.Errorf("image %s is not published for %s (only %s)", img.Name, arch, strings.Join(img.Arches, ", "))
+	}
+	if img.Digest == "" {
+		return img.Repo + ":" + img.Tag, nil
+	}
+	return img.Repo + ":" + img.Tag + "@" + img.Digest, nil
+}
+
+// ParseImageManifest parses an image manifest
+func ParseImageManifest(b []byte) ([]Image, error) {
+	var images []Image
+	if err := json.Unmarshal(b, &images); err != nil {
+		return nil, fmt.Errorf("parsing image manifest: %w", err)
+	}
+	for _, img := range images {
+		if img.Name == "" || img.Repo == "" || img.Tag == "" {
+			return nil, fmt.Errorf("image manifest: %q is missing a name, repo or tag", img.Name)
+		}
+		if img.Digest != "" && !strings.HasPrefix(img.Digest, "sha256:") {
+			return nil, fmt.Errorf("image manifest: %s has invalid digest %q", img.Name, img.Digest)
+		}
+	}
+	return images, nil
+}
+
+// ImageRefs returns image references for the architecture from the
+// bundled manifest, keyed by image name. Images that are auto-updated are
+// run by tag, since podman auto-update follows tags. Other images must be
+// pinned by digest.
+func ImageRefs(arch string, autoUpdate *AutoUpdateConfig, names ...string) (map[string]string, error) {
+	images, err := ParseImageManifest(imagesJSON)
+	if err != nil {
+		return nil, err
+	}
+	if err := autoUpdate.validate(names); err != nil {
+		return nil, err
+	}
+	refs := map[string]string{}
+	for _, name := range names {
+		i := slices.IndexFunc(images, func(img Image) bool { return img.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("image %q is not in the image manifest", name)
+		}
+		img := images[i]
+		switch {
+		case autoUpdate.Enabled(name):
+			img.Digest = ""
+		case img.Digest == "":
+			return nil, fmt.Errorf("image %s is not pinned by digest: run `dreamlab images check -pin` or auto-update it", img.Name)
+		}
+		ref, err := img.Ref(arch)
+		if err != nil {
+			return nil, err
+		}
+		refs[name] = ref
+	}
+	return refs, nil
+}
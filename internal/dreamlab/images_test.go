@@ -0,0 +1,99 @@
+package dreamlab
+
+import (
+	"strings"
+	"testing"
+)
+
+const testImageManifest = `[
+  {"name": "pinned", "repo": "ghcr.io/lab/pinned", "tag": "v1", "digest": "sha256:0123", "arches": ["aarch64", "x86_64"]},
+  {"name": "unpinned", "repo": "ghcr.io/lab/unpinned", "tag": "latest", "digest": "", "arches": ["aarch64", "x86_64"]},
+  {"name": "arm", "repo": "ghcr.io/lab/arm", "tag": "v1", "digest": "sha256:4567", "arches": ["aarch64"]}
+]`
+
+func TestImageRefs(t *testing.T) {
+	manifest := imagesJSON
+	imagesJSON = []byte(testImageManifest)
+	t.Cleanup(func() { imagesJSON = manifest })
+	tests := []struct {
+		name       string
+		arch       string
+		autoUpdate *AutoUpdateConfig
+		images     []string
+		want       map[string]string
+		wantErr    string
+	}{
+		{
+			name:   "pinned",
+			arch:   "x86_64",
+			images: []string{"pinned"},
+			want:   map[string]string{"pinned": "ghcr.io/lab/pinned:v1@sha256:0123"},
+		},
+		{
+			name:    "unpinned",
+			arch:    "x86_64",
+			images:  []string{"pinned", "unpinned"},
+			wantErr: "image unpinned is not pinned by digest",
+		},
+		{
+			name:       "auto-updated",
+			arch:       "x86_64",
+			autoUpdate: &AutoUpdateConfig{Services: []string{"pinned", "unpinned"}},
+			images:     []string{"pinned", "unpinned"},
+			want: map[string]string{
+				"pinned":   "ghcr.io/lab/pinned:v1",
+				"unpinned": "ghcr.io/lab/unpinned:latest",
+			},
+		},
+		{
+			name:    "unpublished arch",
+			arch:    "x86_64",
+			images:  []string{"arm"},
+			wantErr: "image arm is not published for x86_64",
+		},
+		{
+			name:    "not in manifest",
+			arch:    "x86_64",
+			images:  []string{"missing"},
+			wantErr: `image "missing" is not in the image manifest`,
+		},
+		{
+			name:       "unknown auto-update service",
+			arch:       "x86_64",
+			autoUpdate: &AutoUpdateConfig{Services: []string{"arm"}},
+			images:     []string{"pinned"},
+			wantErr:    `auto-update: unknown service "arm"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs, err := ImageRefs(tt.arch, tt.autoUpdate, tt.images...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ImageRefs() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if refs[name] != want {
+					t.Errorf("%s = %q, want %q", name, refs[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseImageManifest(t *testing.T) {
+	if _, err := ParseImageManifest(imagesJSON); err != nil {
+		t.Fatalf("bundled manifest: %v", err)
+	}
+	if _, err := ParseImageManifest([]byte(`[{"name": "a", "repo": "r", "tag": "t", "digest": "md5:00"}]`)); err == nil {
+		t.Error("invalid digest: no error")
+	}
+	if _, err := ParseImageManifest([]byte(`[{"name": "a", "repo": "r"}]`)); err == nil {
+		t.Error("missing tag: no error")
+	}
+}
@@ -0,0 +1,32 @@
+package dreamlab
+
+import (
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/kms"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// NewKMSKey creates a customer managed KMS key with yearly rotation and an
+// alias/<resource> alias. The key policy is the default, which delegates
+// access to IAM in this account. The options are used for both resources,
+// e.g. to create the key in another region.
+func NewKMSKey(ctx *pulumi.Context, resource string, description string, opts ...pulumi.ResourceOption) (*kms.Key, error) {
+	key, err := kms.NewKey(ctx, resource, &kms.KeyArgs{
+		Description:          pulumi.String(description),
+		EnableKeyRotation:    pulumi.Bool(true),
+		DeletionWindowInDays: pulumi.Int(30),
+		Tags: pulumi.StringMap{
+			"Name": pulumi.String(resource),
+		},
+	}, append(opts, pulumi.Protect(true))...)
+	if err != nil {
+		return nil, err
+	}
+	_, err = kms.NewAlias(ctx, resource+"-alias", &kms.AliasArgs{
+		Name:        pulumi.String("alias/" + resource),
+		TargetKeyId: key.KeyId,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,101 @@
+package ocflroot
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// layout maps object ids to object root paths
+type layout interface {
+	path(id string) string
+}
+
+// newLayout returns the storage layout extension with the config, which
+// may be empty to use the extension's defaults. It returns nil if the
+// extension isn't supported.
+func newLayout(extension string, config []byte) (layout, error) {
+	switch extension {
+	case "0002-flat-direct-storage-layout":
+		return flatDirect{}, nil
+	case "0004-hashed-n-tuple-storage-layout":
+		l := &hashedNTuple{
+			DigestAlgorithm: "sha256",
+			TupleSize:       3,
+			NumberOfTuples:  3,
+		}
+		if len(config) > 0 {
+			if err := json.Unmarshal(config, l); err != nil {
+				return nil, fmt.Errorf("parsing %s config: %w", extension, err)
+			}
+		}
+		h, err := newHash(l.DigestAlgorithm)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", extension, err)
+		}
+		if l.TupleSize < 0 || l.NumberOfTuples < 0 || (l.TupleSize == 0) != (l.NumberOfTuples == 0) {
+			return nil, fmt.Errorf("%s: tupleSize and numberOfTuples must both be zero or positive", extension)
+		}
+		if n := l.TupleSize * l.NumberOfTuples; n > 2*h.Size() || (l.ShortObjectRoot && n == 2*h.Size()) {
+			return nil, fmt.Errorf("%s: tuples are longer than the %s digest", extension, l.DigestAlgorithm)
+		}
+		return l, nil
+	}
+	return nil, nil
+}
+
+// 0002-flat-direct-storage-layout: object roots are their ids
+type flatDirect struct{}
+
+func (flatDirect) path(id string) string { return id }
+
+// 0004-hashed-n-tuple-storage-layout: object roots are the hex digest of
+// their id, split into tuples.
+type hashedNTuple struct {
+	DigestAlgorithm string `json:"digestAlgorithm"`
+	TupleSize       int    `json:"tupleSize"`
+	NumberOfTuples  int    `json:"numberOfTuples"`
+	ShortObjectRoot bool   `json:"shortObjectRoot"`
+}
+
+func (l *hashedNTuple) path(id string) string {
+	digest, _ := digestBytes(l.DigestAlgorithm, []byte(id))
+	var parts []string
+	for i := range l.NumberOfTuples {
+		parts = append(parts, digest[i*l.TupleSize:(i+1)*l.TupleSize])
+	}
+	if l.ShortObjectRoot {
+		parts = append(parts, digest[l.NumberOfTuples*l.TupleSize:])
+	} else {
+		parts = append(parts, digest)
+	}
+	return strings.Join(parts, "/")
+}
+
+func newHash(alg string) (hash.Hash, error) {
+	switch alg {
+	case "sha512":
+		return sha512.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "md5":
+		return md5.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported digest algorithm %q", alg)
+}
+
+func digestBytes(alg string, b []byte) (string, error) {
+	h, err := newHash(alg)
+	if err != nil {
+		return "", err
+	}
+	h.Write(b)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,40 @@
+package ocflroot
+
+import "testing"
+
+func TestLayoutPath(t *testing.T) {
+	tests := []struct {
+		extension, config, id, want string
+	}{
+		{"0002-flat-direct-storage-layout", "", "object-01", "object-01"},
+		{"0004-hashed-n-tuple-storage-layout", "", "object-01", "3c0/ff4/240/3c0ff4240c1e116dba14c7627f2319b58aa3d77606d0d90dfc6161608ac987d4"},
+		{"0004-hashed-n-tuple-storage-layout", `{"shortObjectRoot": true}`, "object-01", "3c0/ff4/240/c1e116dba14c7627f2319b58aa3d77606d0d90dfc6161608ac987d4"},
+		{"0004-hashed-n-tuple-storage-layout", `{"digestAlgorithm": "md5", "tupleSize": 0, "numberOfTuples": 0}`, "object-01", "ff75534492485eabb39f86356728884e"},
+	}
+	for _, tt := range tests {
+		l, err := newLayout(tt.extension, []byte(tt.config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := l.path(tt.id); got != tt.want {
+			t.Errorf("%s %s: path(%s) = %s, want %s", tt.extension, tt.config, tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestNewLayoutErrors(t *testing.T) {
+	for _, config := range []string{
+		`{"digestAlgorithm": "crc32"}`,
+		`{"tupleSize": 0}`,
+		`{"tupleSize": 9, "numberOfTuples": 8}`,
+		`{"tupleSize": 32, "numberOfTuples": 2, "shortObjectRoot": true}`,
+		`{`,
+	} {
+		if _, err := newLayout("0004-hashed-n-tuple-storage-layout", []byte(config)); err == nil {
+			t.Errorf("%s: no error", config)
+		}
+	}
+	if l, err := newLayout("0003-hash-and-id-n-tuple-storage-layout", nil); l != nil || err != nil {
+		t.Errorf("unsupported layout = %v, %v", l, err)
+	}
+}
@@ -0,0 +1,305 @@
+package ocflroot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	inventoryFile           = "inventory.json"
+	defaultContentDirectory = "content"
+)
+
+// inventory digest algorithms
+var inventoryAlgorithms = []string{"sha512", "sha256"}
+
+type inventory struct {
+	ID               string              `json:"id"`
+	Type             string              `json:"type"`
+	DigestAlgorithm  string              `json:"digestAlgorithm"`
+	Head             string              `json:"head"`
+	ContentDirectory string              `json:"contentDirectory"`
+	Manifest         map[string][]string `json:"manifest"`
+	Versions         map[string]struct {
+		Created string              `json:"created"`
+		State   map[string][]string `json:"state"`
+	} `json:"versions"`
+}
+
+// checkObject checks the object whose root is dir: its declaration, its
+// inventory and sidecar, the layout of its path, and its content files.
+func (c *checker) checkObject(dir string, entries []fs.DirEntry) {
+	c.report.Objects++
+	spec := c.checkDeclaration(dir, entries, objectSpecs)
+	invFile := path.Join(dir, inventoryFile)
+	invJSON, err := fs.ReadFile(c.fsys, invFile)
+	if err != nil {
+		c.errorf(invFile, "reading inventory: %v", err)
+		return
+	}
+	var inv inventory
+	if err := json.Unmarshal(invJSON, &inv); err != nil {
+		c.errorf(invFile, "parsing inventory: %v", err)
+		return
+	}
+	if !c.checkInventory(invFile, &inv, spec) {
+		return
+	}
+	digest, err := digestBytes(inv.DigestAlgorithm, invJSON)
+	if err != nil {
+		c.errorf(invFile, "%v", err)
+		return
+	}
+	c.checkSidecar(invFile, inv.DigestAlgorithm, digest)
+	if c.layout != nil {
+		if want := c.layout.path(inv.ID); want != dir {
+			c.errorf(dir, "object %q should be at %s in the storage layout", inv.ID, want)
+		}
+	}
+	files, err := c.listFiles(dir)
+	if err != nil {
+		c.errorf(dir, "listing object: %v", err)
+		return
+	}
+	// the head version's inventory must be the same as the root inventory
+	headInv := path.Join(inv.Head, inventoryFile)
+	if files[headInv] {
+		b, err := fs.ReadFile(c.fsys, path.Join(dir, headInv))
+		if err != nil {
+			c.errorf(path.Join(dir, headInv), "reading inventory: %v", err)
+		} else if string(b) != string(invJSON) {
+			c.errorf(path.Join(dir, headInv), "head version's inventory isn't the same as the root inventory")
+		}
+	}
+	for name := range files {
+		v, _, ok := strings.Cut(name, "/")
+		if ok && name == path.Join(v, inventoryFile) && v != inv.Head {
+			c.checkVersionInventory(path.Join(dir, name), &inv, spec)
+		}
+	}
+	c.checkContent(dir, &inv, files)
+}
+
+// checkInventory checks the inventory's structure. It returns false if the
+// inventory is too broken to check the object further.
+func (c *checker) checkInventory(name string, inv *inventory, spec string) bool {
+	ok := true
+	if inv.ID == "" {
+		c.errorf(name, "inventory has no id")
+		ok = false
+	}
+	if spec != "" {
+		want := "https://ocfl.io/" + strings.TrimPrefix(spec, "ocfl_object_") + "/spec/#inventory"
+		if inv.Type != want {
+			c.errorf(name, "inventory type is %q, want %q", inv.Type, want)
+		}
+	}
+	if !slices.Contains(inventoryAlgorithms, inv.DigestAlgorithm) {
+		c.errorf(name, "inventory digest algorithm is %q (use %s)", inv.DigestAlgorithm, strings.Join(inventoryAlgorithms, " or "))
+		ok = false
+	}
+	if cd := inv.ContentDirectory; cd == "." || cd == ".." || strings.Contains(cd, "/") {
+		c.errorf(name, "invalid content directory %q", cd)
+		ok = false
+	}
+	if len(inv.Versions) == 0 {
+		c.errorf(name, "inventory has no versions")
+		return false
+	}
+	if err := checkVersionNames(inv); err != nil {
+		c.errorf(name, "%v", err)
+		ok = false
+	}
+	for v, ver := range inv.Versions {
+		if ver.Created == "" {
+			c.errorf(name, "version %s has no created time", v)
+		}
+		for digest := range ver.State {
+			if _, exists := inv.Manifest[digest]; !exists {
+				c.errorf(name, "version %s state digest %s isn't in the manifest", v, digest)
+			}
+		}
+	}
+	for digest, paths := range inv.Manifest {
+		for _, p := range paths {
+			v, _, _ := strings.Cut(p, "/")
+			if _, exists := inv.Versions[v]; !exists || !strings.HasPrefix(p, v+"/"+inv.contentDirectory()+"/") {
+				c.errorf(name, "manifest path %q for %s isn't in a version's content directory", p, digest)
+			}
+		}
+	}
+	return ok
+}
+
+// checkVersionNames checks that versions are v1 ... vN, all zero-padded to
+// the same width or not padded, and that head is the last version.
+func checkVersionNames(inv *inventory) error {
+	width := -1
+	nums := make([]int, 0, len(inv.Versions))
+	for v := range inv.Versions {
+		digits, ok := strings.CutPrefix(v, "v")
+		n, err := strconv.Atoi(digits)
+		if !ok || err != nil || n < 1 || strings.HasPrefix(digits, "+") || strings.HasPrefix(digits, "-") {
+			return fmt.Errorf("invalid version name %q", v)
+		}
+		w := 0 // not padded
+		if strings.HasPrefix(digits, "0") {
+			w = len(digits)
+		}
+		if width != -1 && w != width {
+			return fmt.Errorf("version names aren't padded consistently")
+		}
+		width = w
+		nums = append(nums, n)
+	}
+	slices.Sort(nums)
+	for i, n := range nums {
+		if n != i+1 {
+			return fmt.Errorf("versions aren't a sequence from v1: missing v%d", i+1)
+		}
+	}
+	if _, ok := inv.Versions[inv.Head]; !ok {
+		return fmt.Errorf("head %q isn't a version", inv.Head)
+	}
+	if n, _ := strconv.Atoi(strings.TrimPrefix(inv.Head, "v")); n != len(nums) {
+		return fmt.Errorf("head %q isn't the last version", inv.Head)
+	}
+	return nil
+}
+
+func (inv *inventory) contentDirectory() string {
+	if inv.ContentDirectory == "" {
+		return defaultContentDirectory
+	}
+	return inv.ContentDirectory
+}
+
+// checkSidecar checks that the inventory's sidecar file has its digest.
+func (c *checker) checkSidecar(invFile, alg, digest string) {
+	name := invFile + "." + alg
+	b, err := fs.ReadFile(c.fsys, name)
+	if err != nil {
+		c.errorf(name, "reading inventory sidecar: %v", err)
+		return
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) != 2 || fields[1] != inventoryFile {
+		c.errorf(name, "sidecar must contain %q", "<digest> "+inventoryFile)
+		return
+	}
+	if !strings.EqualFold(fields[0], digest) {
+		c.errorf(name, "sidecar digest %s doesn't match the inventory's %s digest %s", fields[0], alg, digest)
+	}
+}
+
+// checkVersionInventory checks the inventory and sidecar of a version
+// other than head.
+func (c *checker) checkVersionInventory(name string, root *inventory, spec string) {
+	b, err := fs.ReadFile(c.fsys, name)
+	if err != nil {
+		c.errorf(name, "reading inventory: %v", err)
+		return
+	}
+	var inv inventory
+	if err := json.Unmarshal(b, &inv); err != nil {
+		c.errorf(name, "parsing inventory: %v", err)
+		return
+	}
+	if inv.ID != root.ID {
+		c.errorf(name, "inventory id %q isn't the object's id %q", inv.ID, root.ID)
+	}
+	if !slices.Contains(inventoryAlgorithms, inv.DigestAlgorithm) {
+		c.errorf(name, "inventory digest algorithm is %q (use %s)", inv.DigestAlgorithm, strings.Join(inventoryAlgorithms, " or "))
+		return
+	}
+	digest, err := digestBytes(inv.DigestAlgorithm, b)
+	if err != nil {
+		c.errorf(name, "%v", err)
+		return
+	}
+	c.checkSidecar(name, inv.DigestAlgorithm, digest)
+}
+
+// checkContent checks that the manifest's content files exist and that
+// every content file is in the manifest. With Options.Content, it also
+// checks the files' digests.
+func (c *checker) checkContent(dir string, inv *inventory, files map[string]bool) {
+	inManifest := map[string]bool{}
+	for digest, paths := range inv.Manifest {
+		for _, p := range paths {
+			inManifest[p] = true
+			name := path.Join(dir, p)
+			if !files[p] {
+				c.errorf(name, "content file in the manifest doesn't exist")
+				continue
+			}
+			if !c.opts.Content || c.ctx.Err() != nil {
+				continue
+			}
+			got, err := c.digestFile(inv.DigestAlgorithm, name)
+			if err != nil {
+				c.errorf(name, "reading content: %v", err)
+				continue
+			}
+			if !strings.EqualFold(got, digest) {
+				c.errorf(name, "content %s digest is %s, manifest has %s", inv.DigestAlgorithm, got, digest)
+			}
+		}
+	}
+	for p := range files {
+		v, rest, _ := strings.Cut(p, "/")
+		if _, isVersion := inv.Versions[v]; isVersion && strings.HasPrefix(rest, inv.contentDirectory()+"/") && !inManifest[p] {
+			c.errorf(path.Join(dir, p), "content file isn't in the manifest")
+		}
+	}
+}
+
+// listFiles returns the paths of all files below the object root, relative
+// to it.
+func (c *checker) listFiles(dir string) (map[string]bool, error) {
+	files := map[string]bool{}
+	var list func(rel string) error
+	list = func(rel string) error {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+		entries, err := fs.ReadDir(c.fsys, path.Join(dir, rel))
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			name := path.Join(rel, e.Name())
+			if !e.IsDir() {
+				files[name] = true
+				continue
+			}
+			if err := list(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return files, list(".")
+}
+
+func (c *checker) digestFile(alg, name string) (string, error) {
+	f, err := c.fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h, err := newHash(alg)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
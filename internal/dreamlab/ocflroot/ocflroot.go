@@ -0,0 +1,203 @@
+// Package ocflroot checks OCFL storage roots: the storage root's
+// declaration and layout, and each object's declaration, inventory,
+// inventory sidecar and content files.
+package ocflroot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// storage root and object declarations, by spec version
+var (
+	rootSpecs   = []string{"ocfl_1.0", "ocfl_1.1"}
+	objectSpecs = []string{"ocfl_object_1.0", "ocfl_object_1.1"}
+)
+
+const layoutFile = "ocfl_layout.json"
+
+// Options configures a check
+type Options struct {
+	// Content verifies the digests of content files, which reads every
+	// file in the storage root. Otherwise, content files are only checked
+	// to exist.
+	Content bool
+}
+
+// Report is the result of checking a storage root.
+type Report struct {
+	Root     string    `json:"root"`
+	Spec     string    `json:"spec,omitempty"`   // e.g. ocfl_1.1
+	Layout   string    `json:"layout,omitempty"` // storage layout extension
+	Objects  int       `json:"objects"`
+	Valid    bool      `json:"valid"` // there are no errors
+	Errors   []Problem `json:"errors"`
+	Warnings []Problem `json:"warnings"`
+}
+
+// Problem is an error or warning about a path in the storage root.
+type Problem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+type checker struct {
+	ctx    context.Context
+	fsys   fs.FS
+	opts   Options
+	report *Report
+	layout layout // nil if object paths aren't checked
+}
+
+// Check checks the storage root in fsys, whose name in the report is root.
+// Problems with the storage root are returned in the report; Check stops
+// early if ctx is canceled, with the context's error in the report.
+func Check(ctx context.Context, fsys fs.FS, root string, opts Options) *Report {
+	c := &checker{
+		ctx:    ctx,
+		fsys:   fsys,
+		opts:   opts,
+		report: &Report{Root: root, Errors: []Problem{}, Warnings: []Problem{}},
+	}
+	c.checkRoot()
+	if err := ctx.Err(); err != nil {
+		c.errorf(".", "check stopped: %v", err)
+	}
+	c.report.Valid = len(c.report.Errors) == 0
+	sortProblems(c.report.Errors)
+	sortProblems(c.report.Warnings)
+	return c.report
+}
+
+func sortProblems(problems []Problem) {
+	slices.SortStableFunc(problems, func(a, b Problem) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+}
+
+func (c *checker) errorf(name, format string, args ...any) {
+	c.report.Errors = append(c.report.Errors, Problem{Path: name, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) warnf(name, format string, args ...any) {
+	c.report.Warnings = append(c.report.Warnings, Problem{Path: name, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) checkRoot() {
+	entries, err := fs.ReadDir(c.fsys, ".")
+	if err != nil {
+		c.errorf(".", "reading storage root: %v", err)
+		return
+	}
+	c.report.Spec = c.checkDeclaration(".", entries, rootSpecs)
+	c.checkLayout(entries)
+	for _, e := range entries {
+		if !e.IsDir() || e.Name() == "extensions" {
+			continue
+		}
+		c.walk(e.Name())
+	}
+}
+
+// checkDeclaration checks that the directory has one namaste declaration
+// file ("0=<spec>") for one of the specs, with the expected contents. It
+// returns the declared spec.
+func (c *checker) checkDeclaration(dir string, entries []fs.DirEntry, specs []string) string {
+	var decls []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "0=") && !e.IsDir() {
+			decls = append(decls, e.Name())
+		}
+	}
+	if len(decls) != 1 {
+		c.errorf(dir, "expected one 0=%s declaration, found %d", specs[len(specs)-1], len(decls))
+		return ""
+	}
+	name := path.Join(dir, decls[0])
+	spec := strings.TrimPrefix(decls[0], "0=")
+	if !slices.Contains(specs, spec) {
+		c.errorf(name, "unknown declaration %q (use %s)", spec, strings.Join(specs, ", "))
+		return ""
+	}
+	b, err := fs.ReadFile(c.fsys, name)
+	if err != nil {
+		c.errorf(name, "reading declaration: %v", err)
+		return spec
+	}
+	if string(b) != spec+"\n" {
+		c.errorf(name, "declaration contents must be %q", spec+"\n")
+	}
+	return spec
+}
+
+func (c *checker) checkLayout(entries []fs.DirEntry) {
+	if !slices.ContainsFunc(entries, func(e fs.DirEntry) bool { return e.Name() == layoutFile }) {
+		c.warnf(layoutFile, "storage root has no layout; object paths aren't checked")
+		return
+	}
+	b, err := fs.ReadFile(c.fsys, layoutFile)
+	if err != nil {
+		c.errorf(layoutFile, "reading layout: %v", err)
+		return
+	}
+	var desc struct {
+		Extension   string `json:"extension"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(b, &desc); err != nil {
+		c.errorf(layoutFile, "parsing layout: %v", err)
+		return
+	}
+	if desc.Extension == "" || desc.Description == "" {
+		c.errorf(layoutFile, "layout must have an extension and a description")
+		return
+	}
+	c.report.Layout = desc.Extension
+	configFile := path.Join("extensions", desc.Extension, "config.json")
+	config, err := fs.ReadFile(c.fsys, configFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		c.errorf(configFile, "reading layout config: %v", err)
+		return
+	}
+	c.layout, err = newLayout(desc.Extension, config)
+	switch {
+	case err != nil:
+		c.errorf(configFile, "%v", err)
+	case c.layout == nil:
+		c.warnf(layoutFile, "layout %q isn't supported; object paths aren't checked", desc.Extension)
+	}
+}
+
+// walk checks the storage hierarchy below dir, which is either an object
+// root or a directory of other directories.
+func (c *checker) walk(dir string) {
+	if c.ctx.Err() != nil {
+		return
+	}
+	entries, err := fs.ReadDir(c.fsys, dir)
+	if err != nil {
+		c.errorf(dir, "reading directory: %v", err)
+		return
+	}
+	if slices.ContainsFunc(entries, func(e fs.DirEntry) bool { return strings.HasPrefix(e.Name(), "0=ocfl_object_") }) {
+		c.checkObject(dir, entries)
+		return
+	}
+	if len(entries) == 0 {
+		c.errorf(dir, "empty directory in storage hierarchy")
+	}
+	for _, e := range entries {
+		name := path.Join(dir, e.Name())
+		if !e.IsDir() {
+			c.errorf(name, "file isn't part of an object")
+			continue
+		}
+		c.walk(name)
+	}
+}
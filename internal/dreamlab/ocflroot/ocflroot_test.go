@@ -0,0 +1,136 @@
+package ocflroot
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// the fixture object's root in testdata/root, in the hashed n-tuple layout
+const fixtureObject = "a8e/ea5/640/a8eea564016e52cd49d8162500dab0fbda6e649ec75cf2bbc33a9af4fed36f58"
+
+// fixtureRoot returns a copy of the valid storage root in testdata/root,
+// which tests can break.
+func fixtureRoot(t *testing.T) fstest.MapFS {
+	t.Helper()
+	root := os.DirFS("testdata/root")
+	fsys := fstest.MapFS{}
+	err := fs.WalkDir(root, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(root, name)
+		fsys[name] = &fstest.MapFile{Data: b}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fsys
+}
+
+func TestCheckValid(t *testing.T) {
+	report := Check(context.Background(), fixtureRoot(t), "s3://bucket/ocfl", Options{Content: true})
+	if !report.Valid || len(report.Warnings) != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Spec != "ocfl_1.1" || report.Layout != "0004-hashed-n-tuple-storage-layout" || report.Objects != 1 {
+		t.Errorf("spec %s, layout %s, %d objects", report.Spec, report.Layout, report.Objects)
+	}
+}
+
+func TestCheckBroken(t *testing.T) {
+	obj := func(name string) string { return fixtureObject + "/" + name }
+	tests := []struct {
+		name    string
+		content bool
+		breakFS func(fsys fstest.MapFS)
+		path    string // of the error
+		message string
+	}{
+		{
+			name:    "bad root declaration contents",
+			breakFS: func(fsys fstest.MapFS) { fsys["0=ocfl_1.1"].Data = []byte("ocfl_1.0\n") },
+			path:    "0=ocfl_1.1",
+			message: `declaration contents must be "ocfl_1.1\n"`,
+		},
+		{
+			name: "unknown object declaration",
+			breakFS: func(fsys fstest.MapFS) {
+				fsys[obj("0=ocfl_object_2.0")] = &fstest.MapFile{Data: []byte("ocfl_object_2.0\n")}
+				delete(fsys, obj("0=ocfl_object_1.1"))
+			},
+			path:    obj("0=ocfl_object_2.0"),
+			message: `unknown declaration "ocfl_object_2.0"`,
+		},
+		{
+			name: "bad sidecar digest",
+			breakFS: func(fsys fstest.MapFS) {
+				fsys[obj("inventory.json.sha512")].Data = []byte(strings.Repeat("0", 128) + " inventory.json\n")
+			},
+			path:    obj("inventory.json.sha512"),
+			message: "sidecar digest " + strings.Repeat("0", 128) + " doesn't match",
+		},
+		{
+			name:    "missing content file",
+			breakFS: func(fsys fstest.MapFS) { delete(fsys, obj("v2/content/data/readme.md")) },
+			path:    obj("v2/content/data/readme.md"),
+			message: "content file in the manifest doesn't exist",
+		},
+		{
+			name:    "content file not in the manifest",
+			breakFS: func(fsys fstest.MapFS) { fsys[obj("v2/content/extra.txt")] = &fstest.MapFile{Data: []byte("extra\n")} },
+			path:    obj("v2/content/extra.txt"),
+			message: "content file isn't in the manifest",
+		},
+		{
+			name:    "changed content",
+			content: true,
+			breakFS: func(fsys fstest.MapFS) { fsys[obj("v1/content/hello.txt")].Data = []byte("goodbye\n") },
+			path:    obj("v1/content/hello.txt"),
+			message: "content sha512 digest is",
+		},
+		{
+			name: "layout path mismatch",
+			breakFS: func(fsys fstest.MapFS) {
+				for name, f := range fsys {
+					if rest, ok := strings.CutPrefix(name, fixtureObject+"/"); ok {
+						fsys["a8e/ea5/641/moved/"+rest] = f
+						delete(fsys, name)
+					}
+				}
+			},
+			path:    "a8e/ea5/641/moved",
+			message: `object "ark:/13030/dreamlab-fixture" should be at ` + fixtureObject,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fixtureRoot(t)
+			tt.breakFS(fsys)
+			report := Check(context.Background(), fsys, "s3://bucket/ocfl", Options{Content: tt.content})
+			if report.Valid {
+				t.Fatal("report is valid")
+			}
+			found := false
+			for _, p := range report.Errors {
+				found = found || (p.Path == tt.path && strings.Contains(p.Message, tt.message))
+			}
+			if !found {
+				t.Errorf("errors = %+v, want %s: %s", report.Errors, tt.path, tt.message)
+			}
+		})
+	}
+}
+
+func TestCheckNoLayout(t *testing.T) {
+	fsys := fixtureRoot(t)
+	delete(fsys, layoutFile)
+	report := Check(context.Background(), fsys, "root", Options{})
+	if !report.Valid || len(report.Warnings) != 1 || report.Warnings[0].Path != layoutFile {
+		t.Errorf("report = %+v", report)
+	}
+}
@@ -0,0 +1,99 @@
+// Package pulumitest runs pulumi programs with mock resources for tests.
+package pulumitest
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Resource is a resource registered with Mocks
+type Resource struct {
+	Type   string // e.g. aws:s3/bucketV2:BucketV2
+	Name   string
+	Inputs resource.PropertyMap
+	// ID is the id of an imported (or read) resource
+	ID string
+	// Provider is the resource's explicit provider, as urn::id, if any
+	Provider string
+}
+
+// Mocks records the resources a program registers. Each resource's state
+// is its inputs with an arn, and its id is <name>_id. Invokes return the
+// result of Calls[token], or their arguments.
+type Mocks struct {
+	Calls map[string]func(args resource.PropertyMap) resource.PropertyMap
+	// State adds outputs to resources of a type
+	State map[string]resource.PropertyMap
+
+	mu        sync.Mutex
+	resources []Resource
+	invokes   []string
+}
+
+func (m *Mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resources = append(m.resources, Resource{Type: args.TypeToken, Name: args.Name, Inputs: args.Inputs, ID: args.ID, Provider: args.Provider})
+	state := args.Inputs.Copy()
+	if _, ok := state["arn"]; !ok {
+		state["arn"] = resource.NewStringProperty("arn:mock:" + args.Name)
+	}
+	for k, v := range m.State[args.TypeToken] {
+		state[k] = v
+	}
+	return args.Name + "_id", state, nil
+}
+
+func (m *Mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	m.mu.Lock()
+	m.invokes = append(m.invokes, args.Token)
+	m.mu.Unlock()
+	if call, ok := m.Calls[args.Token]; ok {
+		return call(args.Args), nil
+	}
+	return args.Args, nil
+}
+
+// Resources returns the registered resources, in order.
+func (m *Mocks) Resources() []Resource {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.resources)
+}
+
+// Invoked reports whether the program invoked the function.
+func (m *Mocks) Invoked(token string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Contains(m.invokes, token)
+}
+
+// Find returns the first resource with the name, or nil.
+func (m *Mocks) Find(name string) *Resource {
+	for _, r := range m.Resources() {
+		if r.Name == name {
+			return &r
+		}
+	}
+	return nil
+}
+
+// Run runs the program with the mocks and stack config, e.g.
+// {"aws:region": "us-west-2"}. Config keys without a namespace are in the
+// dreamlab project; object values are json. Run waits for the program's
+// outputs, so tests can read values in ApplyT callbacks.
+func Run(m *Mocks, config map[string]string, program pulumi.RunFunc) error {
+	return pulumi.RunErr(program, pulumi.WithMocks("dreamlab", "test", m), func(info *pulumi.RunInfo) {
+		info.Config = map[string]string{}
+		for k, v := range config {
+			if !strings.Contains(k, ":") {
+				k = "dreamlab:" + k
+			}
+			info.Config[k] = v
+		}
+	})
+}
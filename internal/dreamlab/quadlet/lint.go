@@ -0,0 +1,248 @@
+package quadlet
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PublicLabelPrefix marks a router as intentionally public: LintTraefik
+// doesn't require an auth middleware on routers with a
+// "dreamlab.public.<router>=true" label.
+const PublicLabelPrefix = "dreamlab.public."
+
+// auth middleware types; chains are resolved through their members
+var authMiddlewareTypes = []string{"forwardauth", "basicauth", "digestauth"}
+
+// ParseLabels returns the labels in a rendered container unit, in order.
+func ParseLabels(unit string) ([]Label, error) {
+	var labels []Label
+	scanner := bufio.NewScanner(strings.NewReader(unit))
+	section := ""
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[]")
+			continue
+		}
+		val, ok := strings.CutPrefix(line, "Label=")
+		if !ok || section != "Container" {
+			continue
+		}
+		words, err := splitWords(val)
+		if err != nil {
+			return nil, fmt.Errorf("label %q: %w", val, err)
+		}
+		for _, w := range words {
+			k, v, ok := strings.Cut(w, "=")
+			if !ok {
+				return nil, fmt.Errorf("label %q has no value", w)
+			}
+			labels = append(labels, Label{Key: k, Value: v})
+		}
+	}
+	return labels, scanner.Err()
+}
+
+// splitWords splits a unit file value into words, removing quotes and
+// backslash escapes as quadlet does.
+func splitWords(val string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range val {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// traefik routers and middlewares defined by container labels
+type traefikGraph struct {
+	routers     map[string]map[string]string // router -> option -> value
+	middlewares map[string]map[string]string // middleware -> option -> value
+	public      map[string]bool
+	defined     map[string]string // label key -> unit that set it
+	external    map[string]bool   // name@provider -> authenticates
+}
+
+// LintTraefik checks the rendered traefik labels of the units' containers.
+// It returns an error if a container sets a label more than once, if two
+// containers define the same router or middleware option, if a router uses
+// a middleware no container defines, or if a router on the websecure
+// entrypoint has no auth middleware and isn't marked public (see
+// PublicLabelPrefix). Containers without traefik.enable=true are ignored,
+// like traefik does. Middlewares defined by other providers are given by
+// external, as name@provider keys with whether the middleware
+// authenticates requests.
+func LintTraefik(units []Unit, external map[string]bool) error {
+	g := &traefikGraph{
+		routers:     map[string]map[string]string{},
+		middlewares: map[string]map[string]string{},
+		public:      map[string]bool{},
+		defined:     map[string]string{},
+		external:    external,
+	}
+	var errs []error
+	for _, u := range units {
+		if _, ok := u.(*Container); !ok {
+			continue
+		}
+		labels, err := ParseLabels(u.String())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", u.FileName(), err))
+			continue
+		}
+		if !slices.Contains(labels, TraefikEnable) {
+			continue
+		}
+		errs = append(errs, g.add(u.FileName(), labels)...)
+	}
+	errs = append(errs, g.check()...)
+	return errors.Join(errs...)
+}
+
+func (g *traefikGraph) add(unit string, labels []Label) []error {
+	var errs []error
+	seen := map[string]bool{}
+	for _, l := range labels {
+		if seen[l.Key] {
+			errs = append(errs, fmt.Errorf("%s: label %q is set more than once", unit, l.Key))
+			continue
+		}
+		seen[l.Key] = true
+		if router, ok := strings.CutPrefix(l.Key, PublicLabelPrefix); ok {
+			g.public[router] = l.Value == "true"
+			continue
+		}
+		var objects map[string]map[string]string
+		rest, ok := strings.CutPrefix(l.Key, "traefik.http.routers.")
+		if ok {
+			objects = g.routers
+		} else if rest, ok = strings.CutPrefix(l.Key, "traefik.http.middlewares."); ok {
+			objects = g.middlewares
+		} else {
+			continue
+		}
+		if other, ok := g.defined[l.Key]; ok {
+			errs = append(errs, fmt.Errorf("%s: label %q is also set by %s", unit, l.Key, other))
+			continue
+		}
+		g.defined[l.Key] = unit
+		name, option, _ := strings.Cut(rest, ".")
+		if objects[name] == nil {
+			objects[name] = map[string]string{}
+		}
+		objects[name][strings.ToLower(option)] = l.Value
+	}
+	return errs
+}
+
+func (g *traefikGraph) check() []error {
+	var errs []error
+	names := make([]string, 0, len(g.routers))
+	for name := range g.routers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		opts := g.routers[name]
+		auth := false
+		for _, mw := range splitList(opts["middlewares"]) {
+			if !g.isDefined(mw) {
+				errs = append(errs, fmt.Errorf("router %q: middleware %q is not defined", name, mw))
+				continue
+			}
+			auth = auth || g.isAuth(mw, nil)
+		}
+		// routers without entrypoints are on all entrypoints
+		eps := splitList(opts["entrypoints"])
+		secure := len(eps) == 0 || slices.Contains(eps, "websecure")
+		if secure && !auth && !g.public[name] {
+			errs = append(errs, fmt.Errorf("router %q: no auth middleware on websecure (label %s%s=true if it is public)", name, PublicLabelPrefix, name))
+		}
+	}
+	for name, opts := range g.middlewares {
+		for _, member := range splitList(opts["chain.middlewares"]) {
+			if !g.isDefined(member) {
+				errs = append(errs, fmt.Errorf("middleware %q: chained middleware %q is not defined", name, member))
+			}
+		}
+	}
+	return errs
+}
+
+func (g *traefikGraph) isDefined(name string) bool {
+	if _, ok := g.middlewares[name]; ok {
+		return true
+	}
+	_, ok := g.external[name]
+	return ok
+}
+
+// isAuth returns true if the middleware authenticates requests, directly
+// or as part of a chain.
+func (g *traefikGraph) isAuth(name string, visited []string) bool {
+	if slices.Contains(visited, name) {
+		return false
+	}
+	if auth, ok := g.external[name]; ok {
+		return auth
+	}
+	opts := g.middlewares[name]
+	for opt := range opts {
+		typ, _, _ := strings.Cut(opt, ".")
+		if slices.Contains(authMiddlewareTypes, typ) {
+			return true
+		}
+	}
+	for _, member := range splitList(opts["chain.middlewares"]) {
+		if g.isAuth(member, append(visited, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma separated label value. The @docker provider
+// suffix is removed, since container labels define docker middlewares.
+func splitList(val string) []string {
+	var items []string
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSuffix(strings.TrimSpace(item), "@docker")
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,157 @@
+package quadlet
+
+import (
+	"strings"
+	"testing"
+)
+
+// container returns a traefik-enabled container with the labels
+func container(name string, labels ...Label) *Container {
+	return &Container{Name: name, Image: name + ":v1", Labels: append([]Label{TraefikEnable}, labels...)}
+}
+
+var secureRouter = Router{
+	Name:        "web",
+	EntryPoints: []string{"websecure"},
+	Rule:        "Host(`web.example.edu`)",
+	Middlewares: []string{"auth", "public@file"},
+}
+
+var authMiddleware = Middleware("auth", "forwardauth.address", "http://auth:3000/api/auth/traefik")
+
+// middlewares defined in traefik's dynamic config
+var fileMiddlewares = map[string]bool{"public@file": false, "authenticated@file": true}
+
+func TestLintTraefik(t *testing.T) {
+	tests := []struct {
+		name    string
+		units   []Unit
+		wantErr []string
+	}{
+		{
+			name:  "authenticated router",
+			units: []Unit{container("web", append(secureRouter.Labels(), authMiddleware)...)},
+		},
+		{
+			name: "public router",
+			units: []Unit{container("web", Router{
+				Name: "web", EntryPoints: []string{"websecure"}, Rule: "Host(`web.example.edu`)",
+				Middlewares: []string{"public@file"}, Public: true,
+			}.Labels()...)},
+		},
+		{
+			name: "file auth middleware",
+			units: []Unit{container("web", Router{
+				Name: "web", EntryPoints: []string{"websecure"}, Rule: "Host(`web.example.edu`)",
+				Middlewares: []string{"authenticated@file"},
+			}.Labels()...)},
+		},
+		{
+			name: "auth in a chain",
+			units: []Unit{container("web", append(Router{
+				Name: "web", EntryPoints: []string{"websecure"}, Rule: "Host(`web.example.edu`)",
+				Middlewares: []string{"secure@docker"},
+			}.Labels(), authMiddleware, Middleware("secure", "chain.middlewares", "public@file,auth"))...)},
+		},
+		{
+			name:  "web entrypoint",
+			units: []Unit{container("web", Router{Name: "web", EntryPoints: []string{"web"}, Rule: "Host(`web.example.edu`)"}.Labels()...)},
+		},
+		{
+			name:  "not enabled",
+			units: []Unit{&Container{Name: "web", Labels: Router{Name: "web", Rule: "Host(`web.example.edu`)"}.Labels()}},
+		},
+		{
+			// the original ocfl bug: the router's middlewares label was
+			// set twice, and traefik used only one of them
+			name: "duplicate middlewares label",
+			units: []Unit{container("web", append(secureRouter.Labels(), authMiddleware,
+				Label{"traefik.http.routers.web.middlewares", "public@file"})...)},
+			wantErr: []string{`web.container: label "traefik.http.routers.web.middlewares" is set more than once`},
+		},
+		{
+			name: "option set by two containers",
+			units: []Unit{
+				container("web", append(secureRouter.Labels(), authMiddleware)...),
+				container("other", authMiddleware),
+			},
+			wantErr: []string{`other.container: label "traefik.http.middlewares.auth.forwardauth.address" is also set by web.container`},
+		},
+		{
+			name:    "undefined middleware",
+			units:   []Unit{container("web", secureRouter.Labels()...)},
+			wantErr: []string{`router "web": middleware "auth" is not defined`},
+		},
+		{
+			name: "undefined chained middleware",
+			units: []Unit{container("web", append(secureRouter.Labels(), authMiddleware,
+				Middleware("secure", "chain.middlewares", "missing"))...)},
+			wantErr: []string{`middleware "secure": chained middleware "missing" is not defined`},
+		},
+		{
+			name: "unauthenticated websecure router",
+			units: []Unit{container("web", Router{
+				Name: "web", EntryPoints: []string{"websecure"}, Rule: "Host(`web.example.edu`)",
+				Middlewares: []string{"public@file"},
+			}.Labels()...)},
+			wantErr: []string{`router "web": no auth middleware on websecure`},
+		},
+		{
+			name:    "router on all entrypoints",
+			units:   []Unit{container("web", Router{Name: "web", Rule: "Host(`web.example.edu`)"}.Labels()...)},
+			wantErr: []string{`router "web": no auth middleware on websecure`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := LintTraefik(tt.units, fileMiddlewares)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("LintTraefik() = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("LintTraefik() = nil, want %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("LintTraefik() = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	unit := `[Container]
+Image=web:v1
+Label=traefik.enable=true
+Label="traefik.http.routers.web.rule=HostRegexp(` + "`^(.+\\\\.)?web$`" + `)"
+Label=a=1 'b=two words'
+
+[Service]
+Label=not.a.label=true
+`
+	labels, err := ParseLabels(unit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Label{
+		TraefikEnable,
+		{"traefik.http.routers.web.rule", "HostRegexp(`^(.+\\.)?web$`)"},
+		{"a", "1"},
+		{"b", "two words"},
+	}
+	if len(labels) != len(want) {
+		t.Fatalf("ParseLabels() = %v, want %v", labels, want)
+	}
+	for i := range want {
+		if labels[i] != want[i] {
+			t.Errorf("label %d = %v, want %v", i, labels[i], want[i])
+		}
+	}
+	if _, err := ParseLabels("[Container]\nLabel=\"a=1\n"); err == nil {
+		t.Error("unterminated quote: no error")
+	}
+}
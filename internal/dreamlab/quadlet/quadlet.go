@@ -0,0 +1,219 @@
+// Package quadlet builds podman quadlet units (.container, .volume,
+// .network and .pod files) from typed structs. Units are rendered into
+// /etc/containers/systemd by the hosts' butane templates.
+package quadlet
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dir is where quadlet units are installed on hosts.
+const Dir = "/etc/containers/systemd"
+
+// Unit is a quadlet unit file.
+type Unit interface {
+	// FileName is the unit's file name, e.g. "coder.container"
+	FileName() string
+	// String returns the unit file's contents.
+	String() string
+}
+
+// Path returns the unit's path on the host.
+func Path(u Unit) string {
+	return Dir + "/" + u.FileName()
+}
+
+// Label is a container label. Labels are ordered and keys may repeat, as
+// in a unit file.
+type Label struct {
+	Key   string
+	Value string
+}
+
+func (l Label) String() string {
+	return l.Key + "=" + l.Value
+}
+
+// HealthCheck configures a container's health check command.
+type HealthCheck struct {
+	Cmd         string
+	Interval    string // e.g. "30s"
+	Retries     int
+	StartPeriod string // e.g. "2m"
+}
+
+// Container is a .container unit.
+type Container struct {
+	Name          string // unit name, without .container
+	Description   string
+	ContainerName string
+	Image         string
+	Exec          string
+	// AutoUpdate is "registry" or "local" to enable podman auto-update
+	AutoUpdate string
+	// Notify is "healthy" to delay the unit's start until the container's
+	// health check passes
+	Notify string
+	Health *HealthCheck
+	// ExecStartPost are host commands that must succeed for the unit to
+	// start, e.g. probes of images that have no shell for a health check.
+	ExecStartPost        []string
+	Networks             []string
+	PublishPorts         []string
+	Volumes              []string
+	Environment          []string // KEY=VALUE
+	EnvironmentFiles     []string
+	SecurityLabelDisable bool
+	Labels               []Label
+}
+
+func (c *Container) FileName() string { return c.Name + ".container" }
+
+func (c *Container) String() string {
+	var f file
+	unit := f.section("Unit")
+	unit.add("Description", c.Description)
+	unit.add("After", "network-online.target")
+	unit.add("Wants", "network-online.target")
+	ctr := f.section("Container")
+	ctr.add("ContainerName", c.ContainerName)
+	ctr.add("Image", c.Image)
+	ctr.add("AutoUpdate", c.AutoUpdate)
+	ctr.add("Notify", c.Notify)
+	if h := c.Health; h != nil {
+		ctr.add("HealthCmd", h.Cmd)
+		ctr.add("HealthInterval", h.Interval)
+		if h.Retries > 0 {
+			ctr.add("HealthRetries", fmt.Sprint(h.Retries))
+		}
+		ctr.add("HealthStartPeriod", h.StartPeriod)
+	}
+	ctr.add("Exec", c.Exec)
+	ctr.addAll("Network", c.Networks)
+	ctr.addAll("PublishPort", c.PublishPorts)
+	ctr.addAll("Volume", c.Volumes)
+	for _, env := range c.Environment {
+		ctr.add("Environment", quote(env))
+	}
+	ctr.addAll("EnvironmentFile", c.EnvironmentFiles)
+	if c.SecurityLabelDisable {
+		ctr.add("SecurityLabelDisable", "true")
+	}
+	for _, l := range c.Labels {
+		ctr.add("Label", quote(l.String()))
+	}
+	if len(c.ExecStartPost) > 0 {
+		f.section("Service").addAll("ExecStartPost", c.ExecStartPost)
+	}
+	f.section("Install").add("WantedBy", "multi-user.target")
+	return f.String()
+}
+
+// Volume is a .volume unit.
+type Volume struct {
+	Name       string // unit name, without .volume
+	VolumeName string // podman volume name; default: systemd-<Name>
+}
+
+func (v *Volume) FileName() string { return v.Name + ".volume" }
+
+func (v *Volume) String() string {
+	var f file
+	f.section("Volume").add("VolumeName", v.VolumeName)
+	return f.String()
+}
+
+// Network is a .network unit.
+type Network struct {
+	Name        string // unit name, without .network
+	NetworkName string // podman network name; default: systemd-<Name>
+	Internal    bool
+}
+
+func (n *Network) FileName() string { return n.Name + ".network" }
+
+func (n *Network) String() string {
+	var f file
+	net := f.section("Network")
+	net.add("NetworkName", n.NetworkName)
+	if n.Internal {
+		net.add("Internal", "true")
+	}
+	return f.String()
+}
+
+// Pod is a .pod unit. Containers join the pod with Pod=<Name>.pod.
+type Pod struct {
+	Name         string // unit name, without .pod
+	PodName      string // podman pod name; default: systemd-<Name>
+	Networks     []string
+	PublishPorts []string
+	Volumes      []string
+}
+
+func (p *Pod) FileName() string { return p.Name + ".pod" }
+
+func (p *Pod) String() string {
+	var f file
+	pod := f.section("Pod")
+	pod.add("PodName", p.PodName)
+	pod.addAll("Network", p.Networks)
+	pod.addAll("PublishPort", p.PublishPorts)
+	pod.addAll("Volume", p.Volumes)
+	f.section("Install").add("WantedBy", "multi-user.target")
+	return f.String()
+}
+
+// file is a unit file's sections, in order.
+type file struct {
+	sections []*section
+}
+
+type section struct {
+	name    string
+	entries []Label
+}
+
+func (f *file) section(name string) *section {
+	s := &section{name: name}
+	f.sections = append(f.sections, s)
+	return s
+}
+
+// add adds an entry to the section. Empty values are skipped.
+func (s *section) add(key, value string) {
+	if value != "" {
+		s.entries = append(s.entries, Label{Key: key, Value: value})
+	}
+}
+
+func (s *section) addAll(key string, values []string) {
+	for _, v := range values {
+		s.add(key, v)
+	}
+}
+
+func (f *file) String() string {
+	b := &strings.Builder{}
+	for i, s := range f.sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, "[%s]\n", s.name)
+		for _, e := range s.entries {
+			fmt.Fprintf(b, "%s=%s\n", e.Key, e.Value)
+		}
+	}
+	return b.String()
+}
+
+// quote double-quotes values containing characters quadlet would otherwise
+// split or unescape.
+func quote(v string) string {
+	if !strings.ContainsAny(v, " \t\"'\\") {
+		return v
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(v) + `"`
+}
@@ -0,0 +1,139 @@
+package quadlet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContainerString(t *testing.T) {
+	c := &Container{
+		Name:          "web",
+		Description:   "Web Server",
+		ContainerName: "web",
+		Image:         "ghcr.io/lab/web:v1@sha256:0123",
+		AutoUpdate:    "registry",
+		Notify:        "healthy",
+		Health: &HealthCheck{
+			Cmd:         "wget -q -O /dev/null http://localhost:8080/",
+			Interval:    "30s",
+			Retries:     3,
+			StartPeriod: "2m",
+		},
+		ExecStartPost:    []string{"/usr/bin/curl -fsS http://localhost:8080/"},
+		Exec:             "-listen :8080",
+		Networks:         []string{"lab.network"},
+		PublishPorts:     []string{"8080:8080"},
+		Volumes:          []string{"web-data.volume:/data"},
+		Environment:      []string{"MODE=prod", "GREETING=hello world"},
+		EnvironmentFiles: []string{"/etc/web/web.env"},
+		Labels:           []Label{TraefikEnable},
+	}
+	want := `[Unit]
+Description=Web Server
+After=network-online.target
+Wants=network-online.target
+
+[Container]
+ContainerName=web
+Image=ghcr.io/lab/web:v1@sha256:0123
+AutoUpdate=registry
+Notify=healthy
+HealthCmd=wget -q -O /dev/null http://localhost:8080/
+HealthInterval=30s
+HealthRetries=3
+HealthStartPeriod=2m
+Exec=-listen :8080
+Network=lab.network
+PublishPort=8080:8080
+Volume=web-data.volume:/data
+Environment=MODE=prod
+Environment="GREETING=hello world"
+EnvironmentFile=/etc/web/web.env
+Label=traefik.enable=true
+
+[Service]
+ExecStartPost=/usr/bin/curl -fsS http://localhost:8080/
+
+[Install]
+WantedBy=multi-user.target
+`
+	if got := c.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+	if c.FileName() != "web.container" || Path(c) != "/etc/containers/systemd/web.container" {
+		t.Errorf("FileName() = %s, Path() = %s", c.FileName(), Path(c))
+	}
+}
+
+func TestContainerStringMinimal(t *testing.T) {
+	c := &Container{Name: "job", Image: "job:v1"}
+	got := c.String()
+	for _, key := range []string{"Description=", "AutoUpdate=", "Notify=", "Health", "[Service]", "SecurityLabelDisable"} {
+		if strings.Contains(got, key) {
+			t.Errorf("String() has %s for an unset field:\n%s", key, got)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"traefik.enable=true", "traefik.enable=true"},
+		{"A=hello world", `"A=hello world"`},
+		{`A=say "hi"`, `"A=say \"hi\""`},
+		{"rule=Host(`a.example.edu`)", "rule=Host(`a.example.edu`)"},
+		{"rule=HostRegexp(`^(.+\\.)?coder\\.example\\.edu$`)", "\"rule=HostRegexp(`^(.+\\\\.)?coder\\\\.example\\\\.edu$`)\""},
+		{"A=it's", `"A=it's"`},
+	}
+	for _, tt := range tests {
+		if got := quote(tt.in); got != tt.want {
+			t.Errorf("quote(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+// A HostRegexp rule's backslashes are escaped in the unit file, so
+// quadlet passes the regexp to podman unchanged.
+func TestHostRegexpLabel(t *testing.T) {
+	r := Router{Name: "coder", Rule: "HostRegexp(`^(.+\\.)?coder\\.dreamlab\\.ucsb\\.edu$`)"}
+	c := &Container{Name: "coder", Image: "coder:v1", Labels: r.Labels()}
+	want := "Label=\"traefik.http.routers.coder.rule=HostRegexp(`^(.+\\\\.)?coder\\\\.dreamlab\\\\.ucsb\\\\.edu$`)\"\n"
+	if got := c.String(); !strings.Contains(got, want) {
+		t.Errorf("String() =\n%s\nwant line %s", got, want)
+	}
+	if unquoted := unquote(t, strings.TrimPrefix(strings.TrimSpace(want), "Label=")); unquoted != "traefik.http.routers.coder.rule="+r.Rule {
+		t.Errorf("quadlet would read %s", unquoted)
+	}
+}
+
+// unquote undoes quote, as systemd does when it reads a quoted value.
+func unquote(t *testing.T, v string) string {
+	t.Helper()
+	if !strings.HasPrefix(v, `"`) || !strings.HasSuffix(v, `"`) {
+		t.Fatalf("%s isn't quoted", v)
+	}
+	return strings.NewReplacer(`\\`, `\`, `\"`, `"`).Replace(v[1 : len(v)-1])
+}
+
+func TestVolumeNetworkPodString(t *testing.T) {
+	tests := []struct {
+		unit Unit
+		file string
+		want string
+	}{
+		{&Volume{Name: "data", VolumeName: "data"}, "data.volume", "[Volume]\nVolumeName=data\n"},
+		{&Network{Name: "lab", NetworkName: "lab", Internal: true}, "lab.network", "[Network]\nNetworkName=lab\nInternal=true\n"},
+		{
+			&Pod{Name: "app", PodName: "app", Networks: []string{"lab.network"}, PublishPorts: []string{"443:443"}},
+			"app.pod",
+			"[Pod]\nPodName=app\nNetwork=lab.network\nPublishPort=443:443\n\n[Install]\nWantedBy=multi-user.target\n",
+		},
+	}
+	for _, tt := range tests {
+		if tt.unit.FileName() != tt.file {
+			t.Errorf("FileName() = %s, want %s", tt.unit.FileName(), tt.file)
+		}
+		if got := tt.unit.String(); got != tt.want {
+			t.Errorf("%s String() =\n%s\nwant\n%s", tt.file, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package quadlet
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TraefikEnable is the label that exposes a container to traefik's docker
+// provider.
+var TraefikEnable = Label{Key: "traefik.enable", Value: "true"}
+
+// Router is a traefik http router configured with container labels.
+type Router struct {
+	Name        string
+	Rule        string // e.g. "Host(`data.dreamlab.ucsb.edu`)"
+	EntryPoints []string
+	// Priority overrides traefik's default priority, the length of Rule:
+	// the matching router with the highest priority is used.
+	Priority int
+	// CertResolver enables TLS with certificates for Domains from the
+	// resolver.
+	CertResolver string
+	Domains      []string
+	Middlewares  []string
+	// Public routers don't need an auth middleware: the service is public
+	// or authenticates users itself. See LintTraefik.
+	Public bool
+	// Service is the traefik service for the router, e.g. api@internal.
+	// By default, traefik uses the container's service.
+	Service string
+	// Port is the container port traefik forwards to, if the container
+	// publishes more than one.
+	Port int
+}
+
+// Labels returns the router's labels.
+func (r Router) Labels() []Label {
+	prefix := "traefik.http.routers." + r.Name + "."
+	var labels []Label
+	add := func(key, value string) {
+		labels = append(labels, Label{Key: prefix + key, Value: value})
+	}
+	if len(r.EntryPoints) > 0 {
+		add("entrypoints", strings.Join(r.EntryPoints, ","))
+	}
+	add("rule", r.Rule)
+	if r.Priority > 0 {
+		add("priority", fmt.Sprint(r.Priority))
+	}
+	if r.CertResolver != "" {
+		add("tls", "true")
+		add("tls.certresolver", r.CertResolver)
+		for i, d := range r.Domains {
+			add(fmt.Sprintf("tls.domains[%d].main", i), d)
+		}
+	}
+	if len(r.Middlewares) > 0 {
+		add("middlewares", strings.Join(r.Middlewares, ","))
+	}
+	if r.Public {
+		labels = append(labels, Label{Key: PublicLabelPrefix + r.Name, Value: "true"})
+	}
+	if r.Service != "" {
+		add("service", r.Service)
+	}
+	if r.Port > 0 && r.Service == "" {
+		add("service", r.Name)
+		labels = append(labels, Label{
+			Key:   "traefik.http.services." + r.Name + ".loadbalancer.server.port",
+			Value: fmt.Sprint(r.Port),
+		})
+	}
+	return labels
+}
+
+// Middleware returns a label configuring an option of a traefik
+// middleware, e.g. Middleware("auth", "forwardauth.address", url).
+func Middleware(name, option, value string) Label {
+	return Label{Key: "traefik.http.middlewares." + name + "." + option, Value: value}
+}
@@ -0,0 +1,85 @@
+package quadlet
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRouterLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		router Router
+		want   []Label
+	}{
+		{
+			name: "tls with middlewares",
+			router: Router{
+				Name:         "web",
+				EntryPoints:  []string{"websecure"},
+				Rule:         "Host(`web.example.edu`)",
+				CertResolver: "letsencrypt",
+				Domains:      []string{"web.example.edu", "*.web.example.edu"},
+				Middlewares:  []string{"auth", "public@file"},
+			},
+			want: []Label{
+				{"traefik.http.routers.web.entrypoints", "websecure"},
+				{"traefik.http.routers.web.rule", "Host(`web.example.edu`)"},
+				{"traefik.http.routers.web.tls", "true"},
+				{"traefik.http.routers.web.tls.certresolver", "letsencrypt"},
+				{"traefik.http.routers.web.tls.domains[0].main", "web.example.edu"},
+				{"traefik.http.routers.web.tls.domains[1].main", "*.web.example.edu"},
+				{"traefik.http.routers.web.middlewares", "auth,public@file"},
+			},
+		},
+		{
+			name:   "public with service",
+			router: Router{Name: "dashboard", Rule: "Host(`traefik.example.edu`)", Public: true, Service: "api@internal"},
+			want: []Label{
+				{"traefik.http.routers.dashboard.rule", "Host(`traefik.example.edu`)"},
+				{PublicLabelPrefix + "dashboard", "true"},
+				{"traefik.http.routers.dashboard.service", "api@internal"},
+			},
+		},
+		{
+			name:   "priority",
+			router: Router{Name: "web", Rule: "Host(`web.example.edu`)", Priority: 1000, Public: true},
+			want: []Label{
+				{"traefik.http.routers.web.rule", "Host(`web.example.edu`)"},
+				{"traefik.http.routers.web.priority", "1000"},
+				{PublicLabelPrefix + "web", "true"},
+			},
+		},
+		{
+			name:   "port",
+			router: Router{Name: "api", Rule: "Host(`api.example.edu`)", Port: 9000},
+			want: []Label{
+				{"traefik.http.routers.api.rule", "Host(`api.example.edu`)"},
+				{"traefik.http.routers.api.service", "api"},
+				{"traefik.http.services.api.loadbalancer.server.port", "9000"},
+			},
+		},
+		{
+			name:   "service overrides port",
+			router: Router{Name: "api", Rule: "Host(`api.example.edu`)", Service: "other", Port: 9000},
+			want: []Label{
+				{"traefik.http.routers.api.rule", "Host(`api.example.edu`)"},
+				{"traefik.http.routers.api.service", "other"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.router.Labels(); !slices.Equal(got, tt.want) {
+				t.Errorf("Labels() =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	got := Middleware("auth", "forwardauth.address", "http://tinyauth:3000/api/auth/traefik")
+	want := Label{"traefik.http.middlewares.auth.forwardauth.address", "http://tinyauth:3000/api/auth/traefik"}
+	if got != want {
+		t.Errorf("Middleware() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+// Package registry looks up image digests from OCI distribution (docker v2)
+// registries.
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// manifest media types accepted when resolving a tag. Index types come
+// first so multi-arch images resolve to the index digest.
+var manifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// key="value" parameters in a WWW-Authenticate challenge
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// Client resolves image tags to digests. The zero value uses
+// http.DefaultClient and https.
+type Client struct {
+	HTTP *http.Client
+	// PlainHTTP uses http instead of https, for local registries.
+	PlainHTTP bool
+}
+
+// Digest returns the digest of the manifest (or index) that the tag
+// currently refers to. repo is a full repository name such as
+// "docker.io/library/traefik" or "ghcr.io/coder/coder".
+func (c *Client) Digest(ctx context.Context, repo, tag string) (string, error) {
+	host, name, ok := strings.Cut(repo, "/")
+	if !ok {
+		return "", fmt.Errorf("invalid repository %q: missing registry host", repo)
+	}
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	scheme := "https"
+	if c.PlainHTTP {
+		scheme = "http"
+	}
+	u := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, host, name, tag)
+	resp, err := c.head(ctx, u, "")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := c.token(ctx, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return "", fmt.Errorf("%s:%s: %w", repo, tag, err)
+		}
+		if resp, err = c.head(ctx, u, token); err != nil {
+			return "", err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s:%s: %s", repo, tag, resp.Status)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("%s:%s: registry did not return a digest", repo, tag)
+	}
+	return digest, nil
+}
+
+func (c *Client) head(ctx context.Context, u, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// token gets an anonymous bearer token for the challenge in a
+// WWW-Authenticate header.
+func (c *Client) token(ctx context.Context, challenge string) (string, error) {
+	scheme, params, ok := strings.Cut(challenge, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported auth challenge %q", challenge)
+	}
+	vals := url.Values{}
+	var realm string
+	for _, m := range challengeParam.FindAllStringSubmatch(params, -1) {
+		switch m[1] {
+		case "realm":
+			realm = m[2]
+		case "service", "scope":
+			vals.Set(m[1], m[2])
+		}
+	}
+	if realm == "" {
+		return "", fmt.Errorf("auth challenge has no realm: %q", challenge)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+vals.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("getting registry token: %s", resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("getting registry token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,369 @@
+package dreamlab
+
+import (
+	"dreamlab/internal/dreamlab/iam"
+	"dreamlab/internal/dreamlab/quadlet"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// TraefikEntryPoint is the https entrypoint for service routers
+	TraefikEntryPoint = "websecure"
+	// TraefikCertResolver is the ACME resolver for service certificates
+	TraefikCertResolver = "dnsresolver"
+	// TraefikMetricsPort serves prometheus metrics, if enabled. It is only
+	// reachable from the VPC.
+	TraefikMetricsPort = 8082
+
+	traefikMetricsEntryPoint = "metrics"
+	// above any default priority (rule length) of a service router
+	traefikDashboardPriority = 1000
+	traefikAccessLogFile     = "/var/log/traefik/access.log"
+)
+
+// Container returns the traefik reverse proxy container that runs on every
+// host. Traefik discovers routers from container labels through the podman
+// socket and keeps ACME certificates and access logs in the volumes
+// returned by Volumes. If the dashboard is enabled, it is routed on
+// traefik.<hostname>.<domain> with the authenticated middleware profile,
+// ahead of service routers whose rules also match the host (e.g. coder's
+// wildcard subdomains).
+func (c *TraefikConfig) Container(image, hostname, domain string) *quadlet.Container {
+	ctr := &quadlet.Container{
+		Name:        "traefik",
+		Description: "Traefik Reverse Proxy Container",
+		Image:       image,
+		Environment: []string{"AWS_REGION=us-west-2"},
+		Volumes: []string{
+			"/var/run/podman/podman.sock:/var/run/docker.sock",
+			TraefikStaticFile + ":" + TraefikStaticFile,
+			TraefikDynamicFile + ":" + TraefikDynamicFile,
+			"traefik-acme:/etc/traefik/acme",
+		},
+		SecurityLabelDisable: true,
+	}
+	if c.AccessLog {
+		ctr.Volumes = append(ctr.Volumes, "traefik-logs:"+path.Dir(traefikAccessLogFile))
+	}
+	if c.Dashboard {
+		host := "traefik." + hostname + "." + domain
+		router := quadlet.Router{
+			Name:         "traefik-dashboard",
+			EntryPoints:  []string{TraefikEntryPoint},
+			Rule:         "Host(`" + host + "`)",
+			Priority:     traefikDashboardPriority,
+			CertResolver: TraefikCertResolver,
+			Domains:      []string{host},
+			Middlewares:  []string{ProfileAuthenticated, BaselineCSP},
+			Service:      "api@internal",
+		}
+		ctr.Labels = append([]quadlet.Label{quadlet.TraefikEnable}, router.Labels()...)
+	}
+	return ctr
+}
+
+// Volumes returns the volumes used by traefik's container.
+func (c *TraefikConfig) Volumes() []quadlet.Unit {
+	vols := []quadlet.Unit{&quadlet.Volume{Name: "traefik-acme", VolumeName: "traefik-acme"}}
+	if c.AccessLog {
+		vols = append(vols, &quadlet.Volume{Name: "traefik-logs", VolumeName: "traefik-logs"})
+	}
+	return vols
+}
+
+// Ingress returns security group rules for traefik's private ports: the
+// metrics port is open to the VPC if metrics are enabled.
+func (c *TraefikConfig) Ingress(vpc *AWSVPC) ec2.SecurityGroupIngressArray {
+	if c == nil || !c.Metrics {
+		return nil
+	}
+	return ec2.SecurityGroupIngressArray{
+		&ec2.SecurityGroupIngressArgs{
+			Description: pulumi.String("traefik metrics"),
+			FromPort:    pulumi.Int(TraefikMetricsPort),
+			ToPort:      pulumi.Int(TraefikMetricsPort),
+			Protocol:    pulumi.String("tcp"),
+			CidrBlocks:  pulumi.StringArray{vpc.Vpc.CidrBlock},
+		},
+	}
+}
+
+func (c *TraefikConfig) forwardAuthURL(domain string) string {
+	if c.ForwardAuthURL != "" {
+		return c.ForwardAuthURL
+	}
+	return "https://auth." + domain + "/api/auth/traefik"
+}
+
+// TraefikStaticFile is where hosts' traefik static config is installed.
+const TraefikStaticFile = "/etc/traefik/traefik.yml"
+
+const (
+	acmeProductionCA = "https://acme-v02.api.letsencrypt.org/directory"
+	acmeStagingCA    = "https://acme-staging-v02.api.letsencrypt.org/directory"
+)
+
+var (
+	traefikLogLevels  = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL", "PANIC"}
+	traefikLogFormats = []string{"common", "json"}
+)
+
+// ACMEConfig configures traefik's ACME certificate resolver. It is read
+// from the stack's "acme" config object.
+type ACMEConfig struct {
+	Email string `json:"email"` // account contact, required
+	// Staging uses the Let's Encrypt staging CA, which has higher rate
+	// limits but issues untrusted certificates. Use it for dev stacks.
+	Staging bool `json:"staging"`
+	// Storage is an s3://bucket/prefix URL where hosts keep a copy of
+	// acme.json, so certificates survive replacing the instance and its
+	// volumes. The bucket should block public access and use SSE-S3.
+	Storage string `json:"storage"`
+}
+
+// StorageURL returns the S3 URL of the host's copy of acme.json, or an
+// empty string if ACME storage isn't configured.
+func (c *ACMEConfig) StorageURL(hostname string) string {
+	if c == nil || c.Storage == "" {
+		return ""
+	}
+	return strings.TrimSuffix(c.Storage, "/") + "/" + hostname + "/acme.json"
+}
+
+func (c *ACMEConfig) validate() error {
+	if c.Email == "" {
+		return fmt.Errorf("acme email is required")
+	}
+	if _, err := mail.ParseAddress(c.Email); err != nil {
+		return fmt.Errorf("invalid acme email %q", c.Email)
+	}
+	if c.Storage != "" {
+		bucket, _, _ := strings.Cut(strings.TrimPrefix(c.Storage, "s3://"), "/")
+		if !strings.HasPrefix(c.Storage, "s3://") || bucket == "" {
+			return fmt.Errorf("acme storage %q is not an s3://bucket/prefix URL", c.Storage)
+		}
+	}
+	return nil
+}
+
+func (c *ACMEConfig) caServer() string {
+	if c.Staging {
+		return acmeStagingCA
+	}
+	return acmeProductionCA
+}
+
+// TraefikConfig configures traefik on every host. It is read from the
+// stack's "traefik" config object; ACME is set from the "acme" object.
+type TraefikConfig struct {
+	ACME      *ACMEConfig `json:"-"`
+	LogLevel  string      `json:"logLevel"`  // default: INFO
+	LogFormat string      `json:"logFormat"` // common (default) or json
+	AccessLog bool        `json:"accessLog"` // json access logs in the traefik-logs volume
+	Metrics   bool        `json:"metrics"`   // prometheus metrics on TraefikMetricsPort
+	Dashboard bool        `json:"dashboard"` // dashboard on traefik.<host>.<domain>
+	// ForwardAuthURL is the forward-auth endpoint of the authenticated
+	// middleware profile (default: tinyauth on auth.<domain>)
+	ForwardAuthURL string `json:"forwardAuthURL"`
+	// RateLimit is the average requests per second allowed from each
+	// client IP, with bursts of twice as many (default: 100). InFlight
+	// limits each client IP's concurrent requests (default: 256).
+	RateLimit int `json:"rateLimit"`
+	InFlight  int `json:"inFlight"`
+	// CampusCIDRs are the source ranges allowed by the campus-only
+	// middleware profile, which isn't defined if this is empty.
+	CampusCIDRs []string `json:"campusCIDRs"`
+	// Telemetry enables traefik's version checks and anonymous usage
+	// statistics.
+	Telemetry bool `json:"telemetry"`
+}
+
+func (c *TraefikConfig) validate() error {
+	if c == nil || c.ACME == nil {
+		return fmt.Errorf("traefik: acme config is required")
+	}
+	if err := c.ACME.validate(); err != nil {
+		return fmt.Errorf("traefik: %w", err)
+	}
+	if c.LogLevel != "" && !slices.Contains(traefikLogLevels, c.LogLevel) {
+		return fmt.Errorf("traefik: invalid log level %q (use %s)", c.LogLevel, strings.Join(traefikLogLevels, ", "))
+	}
+	if c.LogFormat != "" && !slices.Contains(traefikLogFormats, c.LogFormat) {
+		return fmt.Errorf("traefik: invalid log format %q (use %s)", c.LogFormat, strings.Join(traefikLogFormats, ", "))
+	}
+	if u, err := url.Parse(c.ForwardAuthURL); c.ForwardAuthURL != "" && (err != nil || u.Scheme == "" || u.Host == "") {
+		return fmt.Errorf("traefik: invalid forward-auth URL %q", c.ForwardAuthURL)
+	}
+	return c.validateMiddlewares()
+}
+
+// Statements returns policy statements for the host's traefik beyond the
+// DNS zone's ACME statements: access to its copy of acme.json if ACME
+// storage is configured.
+func (c *TraefikConfig) Statements(hostname string) []*iam.Statement {
+	if c == nil {
+		return nil
+	}
+	storage := c.ACME.StorageURL(hostname)
+	if storage == "" {
+		return nil
+	}
+	return []*iam.Statement{
+		iam.Allow("s3:GetObject", "s3:PutObject").
+			WithSid("TraefikACMEStorage").
+			On(pulumi.String("arn:aws:s3:::" + strings.TrimPrefix(storage, "s3://"))),
+	}
+}
+
+// traefik static configuration (traefik.yml)
+type traefikStatic struct {
+	Global                traefikGlobal                  `yaml:"global"`
+	Log                   traefikLog                     `yaml:"log"`
+	AccessLog             *traefikAccessLog              `yaml:"accessLog,omitempty"`
+	Metrics               *traefikMetrics                `yaml:"metrics,omitempty"`
+	EntryPoints           map[string]traefikEntryPoint   `yaml:"entryPoints"`
+	CertificatesResolvers map[string]traefikCertResolver `yaml:"certificatesResolvers"`
+	API                   traefikAPI                     `yaml:"api"`
+	Providers             traefikProviders               `yaml:"providers"`
+}
+
+type traefikGlobal struct {
+	CheckNewVersion    bool `yaml:"checkNewVersion"`
+	SendAnonymousUsage bool `yaml:"sendAnonymousUsage"`
+}
+
+type traefikLog struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format,omitempty"`
+}
+
+type traefikAccessLog struct {
+	FilePath string `yaml:"filePath"`
+	Format   string `yaml:"format"`
+}
+
+type traefikMetrics struct {
+	Prometheus traefikPrometheus `yaml:"prometheus"`
+}
+
+type traefikPrometheus struct {
+	EntryPoint string `yaml:"entryPoint"`
+}
+
+type traefikEntryPoint struct {
+	Address string                 `yaml:"address"`
+	HTTP    *traefikEntryPointHTTP `yaml:"http,omitempty"`
+}
+
+type traefikEntryPointHTTP struct {
+	Redirections traefikRedirections `yaml:"redirections"`
+}
+
+type traefikRedirections struct {
+	EntryPoint traefikRedirect `yaml:"entryPoint"`
+}
+
+type traefikRedirect struct {
+	To     string `yaml:"to"`
+	Scheme string `yaml:"scheme"`
+}
+
+type traefikCertResolver struct {
+	ACME traefikACME `yaml:"acme"`
+}
+
+type traefikACME struct {
+	Email        string              `yaml:"email"`
+	CAServer     string              `yaml:"caServer"`
+	Storage      string              `yaml:"storage"`
+	DNSChallenge traefikDNSChallenge `yaml:"dnsChallenge"`
+}
+
+type traefikDNSChallenge struct {
+	Provider string `yaml:"provider"`
+}
+
+type traefikAPI struct {
+	Dashboard bool `yaml:"dashboard"`
+}
+
+type traefikProviders struct {
+	Docker traefikDocker `yaml:"docker"`
+	File   traefikFile   `yaml:"file"`
+}
+
+type traefikFile struct {
+	Filename string `yaml:"filename"`
+}
+
+type traefikDocker struct {
+	ExposedByDefault bool `yaml:"exposedByDefault"`
+}
+
+// StaticConfig returns the contents of traefik.yml: http is redirected to
+// the https TraefikEntryPoint, certificates come from TraefikCertResolver
+// with route53 dns challenges, routers are read from container labels, and
+// the middleware profiles from TraefikDynamicFile.
+func (c *TraefikConfig) StaticConfig() (string, error) {
+	if err := c.validate(); err != nil {
+		return "", err
+	}
+	level := c.LogLevel
+	if level == "" {
+		level = "INFO"
+	}
+	static := traefikStatic{
+		Global: traefikGlobal{
+			CheckNewVersion:    c.Telemetry,
+			SendAnonymousUsage: c.Telemetry,
+		},
+		Log: traefikLog{Level: level, Format: c.LogFormat},
+		EntryPoints: map[string]traefikEntryPoint{
+			"web": {
+				Address: ":80",
+				HTTP: &traefikEntryPointHTTP{
+					Redirections: traefikRedirections{
+						EntryPoint: traefikRedirect{To: TraefikEntryPoint, Scheme: "https"},
+					},
+				},
+			},
+			TraefikEntryPoint: {Address: ":443"},
+		},
+		CertificatesResolvers: map[string]traefikCertResolver{
+			TraefikCertResolver: {ACME: traefikACME{
+				Email:        c.ACME.Email,
+				CAServer:     c.ACME.caServer(),
+				Storage:      "/etc/traefik/acme/acme.json",
+				DNSChallenge: traefikDNSChallenge{Provider: "route53"},
+			}},
+		},
+		API: traefikAPI{Dashboard: c.Dashboard},
+		Providers: traefikProviders{
+			Docker: traefikDocker{ExposedByDefault: false},
+			File:   traefikFile{Filename: TraefikDynamicFile},
+		},
+	}
+	if c.AccessLog {
+		static.AccessLog = &traefikAccessLog{FilePath: traefikAccessLogFile, Format: "json"}
+	}
+	if c.Metrics {
+		static.EntryPoints[traefikMetricsEntryPoint] = traefikEntryPoint{Address: fmt.Sprintf(":%d", TraefikMetricsPort)}
+		static.Metrics = &traefikMetrics{Prometheus: traefikPrometheus{EntryPoint: traefikMetricsEntryPoint}}
+	}
+	b := &strings.Builder{}
+	enc := yaml.NewEncoder(b)
+	enc.SetIndent(2)
+	if err := enc.Encode(static); err != nil {
+		return "", err
+	}
+	return b.String(), enc.Close()
+}
@@ -0,0 +1,179 @@
+package dreamlab
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Middleware profiles provided by traefik's file provider. Service routers
+// opt into a profile by listing it in their middlewares.
+const (
+	// ProfilePublic sets security headers (HSTS, nosniff and a referrer
+	// policy) and limits the request rate and concurrent requests of each
+	// client IP. It doesn't set a content security policy, which would
+	// replace the service's own.
+	ProfilePublic = "public@file"
+	// ProfileCampusOnly is ProfilePublic restricted to the stack's campus
+	// CIDRs. It is only defined if campusCIDRs is set.
+	ProfileCampusOnly = "campus-only@file"
+	// ProfileAuthenticated is ProfilePublic with forward-auth.
+	ProfileAuthenticated = "authenticated@file"
+	// BaselineCSP sets a baseline content security policy, for routers to
+	// services that don't send their own. It replaces a service's policy.
+	BaselineCSP = "baseline-csp@file"
+)
+
+// TraefikDynamicFile is where hosts' traefik dynamic config, which defines
+// the middleware profiles, is installed.
+const TraefikDynamicFile = "/etc/traefik/dynamic.yml"
+
+const (
+	traefikDefaultRateLimit = 100 // requests per second per client IP
+	traefikDefaultInFlight  = 256 // concurrent requests per client IP
+	traefikHSTSSeconds      = 31536000
+
+	// baseline content security policy: it restricts what the page may be
+	// embedded in and where it may load plugins and set its base URL from,
+	// which doesn't break services that load their own scripts and styles.
+	traefikBaselineCSP = "frame-ancestors 'self'; base-uri 'self'; object-src 'none'"
+)
+
+// traefik dynamic configuration (dynamic.yml)
+type traefikDynamic struct {
+	HTTP traefikDynamicHTTP `yaml:"http"`
+}
+
+type traefikDynamicHTTP struct {
+	Middlewares map[string]traefikMiddleware `yaml:"middlewares"`
+}
+
+type traefikMiddleware struct {
+	Headers     *traefikHeaders     `yaml:"headers,omitempty"`
+	RateLimit   *traefikRateLimit   `yaml:"rateLimit,omitempty"`
+	InFlightReq *traefikInFlightReq `yaml:"inFlightReq,omitempty"`
+	IPAllowList *traefikIPAllowList `yaml:"ipAllowList,omitempty"`
+	ForwardAuth *traefikForwardAuth `yaml:"forwardAuth,omitempty"`
+	Chain       *traefikChain       `yaml:"chain,omitempty"`
+}
+
+type traefikHeaders struct {
+	CustomRequestHeaders  map[string]string `yaml:"customRequestHeaders,omitempty"`
+	STSSeconds            int               `yaml:"stsSeconds,omitempty"`
+	STSIncludeSubdomains  bool              `yaml:"stsIncludeSubdomains,omitempty"`
+	ContentTypeNosniff    bool              `yaml:"contentTypeNosniff,omitempty"`
+	ReferrerPolicy        string            `yaml:"referrerPolicy,omitempty"`
+	ContentSecurityPolicy string            `yaml:"contentSecurityPolicy,omitempty"`
+}
+
+// rate and in-flight limits apply per remote address by default
+type traefikRateLimit struct {
+	Average int `yaml:"average"`
+	Burst   int `yaml:"burst"`
+}
+
+type traefikInFlightReq struct {
+	Amount int `yaml:"amount"`
+}
+
+type traefikIPAllowList struct {
+	SourceRange []string `yaml:"sourceRange"`
+}
+
+type traefikForwardAuth struct {
+	Address string `yaml:"address"`
+}
+
+type traefikChain struct {
+	Middlewares []string `yaml:"middlewares"`
+}
+
+// middlewares returns the dynamic config's middlewares by name, without
+// the @file suffix.
+func (c *TraefikConfig) middlewares(domain string) map[string]traefikMiddleware {
+	rate := c.RateLimit
+	if rate == 0 {
+		rate = traefikDefaultRateLimit
+	}
+	inFlight := c.InFlight
+	if inFlight == 0 {
+		inFlight = traefikDefaultInFlight
+	}
+	base := []string{"security-headers", "rate-limit", "in-flight"}
+	mws := map[string]traefikMiddleware{
+		"security-headers": {Headers: &traefikHeaders{
+			CustomRequestHeaders: map[string]string{"X-Forwarded-Proto": "https"},
+			STSSeconds:           traefikHSTSSeconds,
+			STSIncludeSubdomains: true,
+			ContentTypeNosniff:   true,
+			ReferrerPolicy:       "strict-origin-when-cross-origin",
+		}},
+		"baseline-csp": {Headers: &traefikHeaders{ContentSecurityPolicy: traefikBaselineCSP}},
+		"rate-limit":   {RateLimit: &traefikRateLimit{Average: rate, Burst: 2 * rate}},
+		"in-flight":    {InFlightReq: &traefikInFlightReq{Amount: inFlight}},
+		"forward-auth": {ForwardAuth: &traefikForwardAuth{Address: c.forwardAuthURL(domain)}},
+		"public":       {Chain: &traefikChain{Middlewares: base}},
+		// forward-auth runs after the limits, so requests that fail it
+		// are still limited
+		"authenticated": {Chain: &traefikChain{
+			Middlewares: append(slices.Clone(base), "forward-auth"),
+		}},
+	}
+	if len(c.CampusCIDRs) > 0 {
+		mws["campus-allow"] = traefikMiddleware{IPAllowList: &traefikIPAllowList{SourceRange: c.CampusCIDRs}}
+		mws["campus-only"] = traefikMiddleware{Chain: &traefikChain{
+			Middlewares: append([]string{"campus-allow"}, base...),
+		}}
+	}
+	return mws
+}
+
+// FileMiddlewares returns the names of the middlewares defined by the
+// dynamic config, with the @file suffix, and whether each authenticates
+// requests. It is used to lint routers that reference them.
+func (c *TraefikConfig) FileMiddlewares() map[string]bool {
+	names := map[string]bool{}
+	for name, mw := range c.middlewares("") {
+		auth := mw.ForwardAuth != nil
+		if mw.Chain != nil {
+			auth = auth || slices.Contains(mw.Chain.Middlewares, "forward-auth")
+		}
+		names[name+"@file"] = auth
+	}
+	return names
+}
+
+// DynamicConfig returns the contents of traefik's dynamic.yml, which
+// defines the middleware profiles (see ProfilePublic) for the domain's
+// hosts.
+func (c *TraefikConfig) DynamicConfig(domain string) (string, error) {
+	if err := c.validate(); err != nil {
+		return "", err
+	}
+	dynamic := traefikDynamic{HTTP: traefikDynamicHTTP{Middlewares: c.middlewares(domain)}}
+	b := &strings.Builder{}
+	enc := yaml.NewEncoder(b)
+	enc.SetIndent(2)
+	if err := enc.Encode(dynamic); err != nil {
+		return "", err
+	}
+	return b.String(), enc.Close()
+}
+
+func (c *TraefikConfig) validateMiddlewares() error {
+	if c.RateLimit < 0 {
+		return fmt.Errorf("traefik: rate limit must be positive")
+	}
+	if c.InFlight < 0 {
+		return fmt.Errorf("traefik: in-flight limit must be positive")
+	}
+	for _, cidr := range c.CampusCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("traefik: invalid campus CIDR %q", cidr)
+		}
+	}
+	return nil
+}
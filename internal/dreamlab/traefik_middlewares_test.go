@@ -0,0 +1,80 @@
+package dreamlab
+
+import (
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func testDynamicConfig(t *testing.T, c *TraefikConfig) map[string]traefikMiddleware {
+	t.Helper()
+	out, err := c.DynamicConfig("dreamlab.ucsb.edu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dynamic traefikDynamic
+	if err := yaml.Unmarshal([]byte(out), &dynamic); err != nil {
+		t.Fatal(err)
+	}
+	return dynamic.HTTP.Middlewares
+}
+
+func TestDynamicConfigChains(t *testing.T) {
+	mws := testDynamicConfig(t, &TraefikConfig{
+		ACME:        &ACMEConfig{Email: "admin@ucsb.edu"},
+		CampusCIDRs: []string{"128.111.0.0/16"},
+	})
+	chains := map[string][]string{
+		"public": {"security-headers", "rate-limit", "in-flight"},
+		// limits apply before forward-auth, so failed requests count
+		"authenticated": {"security-headers", "rate-limit", "in-flight", "forward-auth"},
+		"campus-only":   {"campus-allow", "security-headers", "rate-limit", "in-flight"},
+	}
+	for name, want := range chains {
+		if got := mws[name].Chain; got == nil || !slices.Equal(got.Middlewares, want) {
+			t.Errorf("%s chain = %v, want %v", name, got, want)
+		}
+	}
+	if got := mws["forward-auth"].ForwardAuth; got == nil || got.Address != "https://auth.dreamlab.ucsb.edu/api/auth/traefik" {
+		t.Errorf("forward-auth = %+v", got)
+	}
+}
+
+// The profiles don't replace a service's content security policy (e.g.
+// coder's): only the baseline-csp middleware sets one.
+func TestDynamicConfigCSP(t *testing.T) {
+	mws := testDynamicConfig(t, &TraefikConfig{ACME: &ACMEConfig{Email: "admin@ucsb.edu"}})
+	for _, profile := range []string{"public", "authenticated"} {
+		for _, name := range mws[profile].Chain.Middlewares {
+			if h := mws[name].Headers; h != nil && h.ContentSecurityPolicy != "" {
+				t.Errorf("%s: %s sets a content security policy", profile, name)
+			}
+		}
+	}
+	if h := mws["baseline-csp"].Headers; h == nil || h.ContentSecurityPolicy != traefikBaselineCSP {
+		t.Errorf("baseline-csp = %+v", h)
+	}
+	if h := mws["security-headers"].Headers; h == nil || h.STSSeconds != traefikHSTSSeconds || !h.ContentTypeNosniff {
+		t.Errorf("security-headers = %+v", h)
+	}
+}
+
+func TestFileMiddlewares(t *testing.T) {
+	got := (&TraefikConfig{ACME: &ACMEConfig{Email: "admin@ucsb.edu"}}).FileMiddlewares()
+	want := map[string]bool{
+		ProfilePublic:           false,
+		ProfileAuthenticated:    true,
+		BaselineCSP:             false,
+		"forward-auth@file":     true,
+		"security-headers@file": false,
+	}
+	for name, auth := range want {
+		if a, ok := got[name]; !ok || a != auth {
+			t.Errorf("%s: defined %v, auth %v; want auth %v", name, ok, a, auth)
+		}
+	}
+	if _, ok := got[ProfileCampusOnly]; ok {
+		t.Errorf("%s is defined without campus CIDRs", ProfileCampusOnly)
+	}
+}
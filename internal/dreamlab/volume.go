@@ -0,0 +1,225 @@
+package dreamlab
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ebs"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	// name and mount point of every host's main persistent volume
+	varVolumeName = "var"
+	varVolumePath = "/var/lib/containers/storage/volumes"
+
+	defaultVolumeSize = 64 // GiB
+)
+
+// VolumeConfig configures a host's persistent EBS volume. Volumes are gp3;
+// zero Iops and Throughput use the gp3 baseline.
+type VolumeConfig struct {
+	// Name and Path are required for additional volumes: the volume's
+	// resource is named <host resource>-<name> and it is mounted at path.
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	Size       int    `json:"size"`       // GiB, default 64
+	Iops       int    `json:"iops"`       // 3000-16000
+	Throughput int    `json:"throughput"` // MiB/s, 125-1000
+}
+
+func (c VolumeConfig) size() int {
+	if c.Size == 0 {
+		return defaultVolumeSize
+	}
+	return c.Size
+}
+
+func (c VolumeConfig) validate() error {
+	if c.Size < 0 || c.Size > 16384 {
+		return fmt.Errorf("volume %q: size must be between 1 and 16384 GiB", c.Name)
+	}
+	if c.Iops != 0 && (c.Iops < 3000 || c.Iops > 16000) {
+		return fmt.Errorf("volume %q: iops must be between 3000 and 16000", c.Name)
+	}
+	if c.Throughput != 0 && (c.Throughput < 125 || c.Throughput > 1000) {
+		return fmt.Errorf("volume %q: throughput must be between 125 and 1000 MiB/s", c.Name)
+	}
+	return nil
+}
+
+// HostVolumesArgs configures NewHostVolumes
+type HostVolumesArgs struct {
+	Hostname         string
+	AvailabilityZone pulumi.StringInput
+	Var              VolumeConfig   // volume for container volumes
+	Extra            []VolumeConfig // additional volumes
+	// KMSKeyArn is the key used to encrypt the volumes. If nil, volumes
+	// are encrypted with the AWS managed key.
+	KMSKeyArn pulumi.StringInput
+	// RestoreFromSnapshot is a snapshot to create the var volume from.
+	RestoreFromSnapshot string
+}
+
+// HostVolume is a persistent EBS volume for a host.
+type HostVolume struct {
+	Name   string // "var" or the additional volume's name
+	Path   string // mount point on the host
+	Device string // device name for the attachment
+	*ebs.Volume
+}
+
+// Mount is a host volume's stable device path and mount point, used in
+// butane templates.
+type Mount struct {
+	Device string
+	Path   string
+}
+
+// NewHostVolumes creates the host's persistent volumes. The volumes are
+// encrypted and tagged with HostTag for backups.
+//
+// Changes to snapshotId, encrypted and kmsKeyId are ignored so that
+// config changes never replace an existing (populated) volume: volumes
+// created before encryption was enabled stay unencrypted until they are
+// replaced explicitly with `pulumi up --replace`.
+func NewHostVolumes(ctx *pulumi.Context, resource string, args *HostVolumesArgs) ([]*HostVolume, error) {
+	varCfg := args.Var
+	varCfg.Name, varCfg.Path = varVolumeName, varVolumePath
+	configs := append([]VolumeConfig{varCfg}, args.Extra...)
+	names := map[string]bool{}
+	paths := map[string]bool{}
+	var vols []*HostVolume
+	for i, cfg := range configs {
+		switch {
+		case cfg.Name == "" || cfg.Path == "":
+			return nil, fmt.Errorf("%s: additional volumes require a name and path", resource)
+		case names[cfg.Name]:
+			return nil, fmt.Errorf("%s: duplicate volume name %q", resource, cfg.Name)
+		case paths[cfg.Path]:
+			return nil, fmt.Errorf("%s: duplicate volume path %q", resource, cfg.Path)
+		case !path.IsAbs(cfg.Path):
+			return nil, fmt.Errorf("%s: volume path %q is not absolute", resource, cfg.Path)
+		case i >= 10:
+			return nil, fmt.Errorf("%s: too many volumes", resource)
+		}
+		names[cfg.Name], paths[cfg.Path] = true, true
+		if err := cfg.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", resource, err)
+		}
+		volResource := resource + "-" + cfg.Name
+		volArgs := &ebs.VolumeArgs{
+			AvailabilityZone: args.AvailabilityZone,
+			Size:             pulumi.IntPtr(cfg.size()),
+			Type:             pulumi.StringPtr("gp3"),
+			Encrypted:        pulumi.BoolPtr(true),
+			Tags: pulumi.StringMap{
+				"Name":  pulumi.String(volResource),
+				HostTag: pulumi.String(args.Hostname),
+			},
+		}
+		if cfg.Iops > 0 {
+			volArgs.Iops = pulumi.IntPtr(cfg.Iops)
+		}
+		if cfg.Throughput > 0 {
+			volArgs.Throughput = pulumi.IntPtr(cfg.Throughput)
+		}
+		if args.KMSKeyArn != nil {
+			volArgs.KmsKeyId = args.KMSKeyArn.ToStringOutput().ToStringPtrOutput()
+		}
+		if snap := args.RestoreFromSnapshot; snap != "" && cfg.Name == varVolumeName {
+			warning, err := checkRestore(ctx, volResource, args.Hostname, snap, cfg.size())
+			if err != nil {
+				return nil, err
+			}
+			if warning != "" {
+				ctx.Log.Warn(warning, nil)
+			}
+			volArgs.SnapshotId = pulumi.StringPtr(snap)
+		}
+		vol, err := ebs.NewVolume(ctx, volResource, volArgs,
+			pulumi.IgnoreChanges([]string{"snapshotId", "encrypted", "kmsKeyId"}))
+		if err != nil {
+			return nil, err
+		}
+		vols = append(vols, &HostVolume{
+			Name:   cfg.Name,
+			Path:   cfg.Path,
+			Device: fmt.Sprintf("/dev/sd%c", 'f'+i),
+			Volume: vol,
+		})
+	}
+	return vols, nil
+}
+
+// checkRestore checks the snapshot to restore the var volume from. The
+// snapshot only matters when the volume is created: if it already exists,
+// snapshotId changes are ignored, so checkRestore returns a warning that
+// the snapshot isn't applied instead (retention may also have deleted the
+// snapshot since the restore).
+func checkRestore(ctx *pulumi.Context, volResource, hostname, snap string, size int) (string, error) {
+	checkErr := CheckRestoreSnapshot(ctx, hostname, snap, size)
+	exists, err := hostVolumeExists(ctx, volResource, hostname)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case !exists:
+		return "", checkErr
+	case checkErr != nil:
+		return fmt.Sprintf("%v; %s already exists, so it isn't restored (unset the restore config)", checkErr, volResource), nil
+	default:
+		return fmt.Sprintf("%s already exists, so snapshot %s isn't restored unless the volume is replaced (pulumi up --replace); otherwise unset the restore config", volResource, snap), nil
+	}
+}
+
+// hostVolumeExists reports whether the host's volume resource exists in
+// AWS, by its tags.
+func hostVolumeExists(ctx *pulumi.Context, volResource, hostname string) (bool, error) {
+	vols, err := ebs.GetEbsVolumes(ctx, &ebs.GetEbsVolumesArgs{
+		Tags: map[string]string{
+			"Name":  volResource,
+			HostTag: hostname,
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("looking up %s: %w", volResource, err)
+	}
+	return len(vols.Ids) > 0, nil
+}
+
+// AttachHostVolumes attaches the volumes to the host's instance
+func AttachHostVolumes(ctx *pulumi.Context, resource string, instanceID pulumi.IDOutput, vols []*HostVolume) error {
+	for _, v := range vols {
+		_, err := ec2.NewVolumeAttachment(ctx, resource+"-"+v.Name+"-attach", &ec2.VolumeAttachmentArgs{
+			InstanceId:                  instanceID,
+			VolumeId:                    v.ID(),
+			DeviceName:                  pulumi.String(v.Device),
+			StopInstanceBeforeDetaching: pulumi.BoolPtr(true),
+		}, pulumi.DeleteBeforeReplace(true))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VolumeIDs returns the IDs of the volumes, in order.
+func VolumeIDs(vols []*HostVolume) pulumi.StringArrayOutput {
+	ids := make(pulumi.StringArray, len(vols))
+	for i, v := range vols {
+		ids[i] = v.ID().ToStringOutput()
+	}
+	return ids.ToStringArrayOutput()
+}
+
+// Mounts returns the mounts for the volumes given their resolved IDs (see
+// VolumeIDs).
+func Mounts(vols []*HostVolume, ids []string) []Mount {
+	mounts := make([]Mount, len(vols))
+	for i, v := range vols {
+		mounts[i] = Mount{Device: EBSDevicePath(ids[i]), Path: v.Path}
+	}
+	return mounts
+}
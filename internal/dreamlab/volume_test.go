@@ -0,0 +1,174 @@
+package dreamlab
+
+import (
+	"dreamlab/internal/dreamlab/pulumitest"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	getSnapshot   = "aws:ebs/getSnapshot:getSnapshot"
+	getEbsVolumes = "aws:ebs/getEbsVolumes:getEbsVolumes"
+)
+
+func TestNewHostVolumesRestore(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapHost  string // host the snapshot is tagged for
+		snapSize  float64
+		volExists bool // the var volume exists in AWS
+		err       string
+	}{
+		{name: "restore", snapHost: "coder", snapSize: 64},
+		{name: "other host", snapHost: "data", snapSize: 64, err: `tagged for host "data"`},
+		{name: "too large", snapHost: "coder", snapSize: 128, err: "larger than"},
+		// e.g. retention deleted the snapshot after the restore
+		{name: "volume exists", snapHost: "data", snapSize: 64, volExists: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var volIDs []resource.PropertyValue
+			if tt.volExists {
+				volIDs = append(volIDs, resource.NewStringProperty("vol-0123"))
+			}
+			mocks := &pulumitest.Mocks{Calls: map[string]func(resource.PropertyMap) resource.PropertyMap{
+				getSnapshot: func(resource.PropertyMap) resource.PropertyMap {
+					return resource.PropertyMap{
+						"state":      resource.NewStringProperty("completed"),
+						"volumeSize": resource.NewNumberProperty(tt.snapSize),
+						"tags": resource.NewObjectProperty(resource.PropertyMap{
+							HostTag: resource.NewStringProperty(tt.snapHost),
+						}),
+					}
+				},
+				getEbsVolumes: func(resource.PropertyMap) resource.PropertyMap {
+					return resource.PropertyMap{"ids": resource.NewArrayProperty(volIDs)}
+				},
+			}}
+			err := pulumitest.Run(mocks, nil, func(ctx *pulumi.Context) error {
+				_, err := NewHostVolumes(ctx, "coder", &HostVolumesArgs{
+					Hostname:            "coder",
+					AvailabilityZone:    pulumi.String("us-west-2a"),
+					RestoreFromSnapshot: "snap-0123",
+				})
+				return err
+			})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error is %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			vol := mocks.Find("coder-var")
+			if vol == nil {
+				t.Fatal("no coder-var volume")
+			}
+			if got := vol.Inputs["snapshotId"]; !got.IsString() || got.StringValue() != "snap-0123" {
+				t.Errorf("snapshotId is %v", got)
+			}
+		})
+	}
+}
+
+func TestNewHostVolumesNoRestore(t *testing.T) {
+	mocks := &pulumitest.Mocks{}
+	err := pulumitest.Run(mocks, nil, func(ctx *pulumi.Context) error {
+		_, err := NewHostVolumes(ctx, "coder", &HostVolumesArgs{
+			Hostname:         "coder",
+			AvailabilityZone: pulumi.String("us-west-2a"),
+			Extra:            []VolumeConfig{{Name: "scratch", Path: "/var/scratch", Size: 100}},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mocks.Invoked(getSnapshot) {
+		t.Error("looked up a snapshot without a restore config")
+	}
+	for _, name := range []string{"coder-var", "coder-scratch"} {
+		vol := mocks.Find(name)
+		if vol == nil {
+			t.Fatalf("no %s volume", name)
+		}
+		if _, ok := vol.Inputs["snapshotId"]; ok {
+			t.Errorf("%s has a snapshotId", name)
+		}
+	}
+}
+
+// An existing volume is never restored, so a restore config warns whether
+// or not the snapshot is valid.
+func TestCheckRestore(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapHost  string
+		volExists bool
+		warning   string
+		err       string
+	}{
+		{name: "new volume", snapHost: "coder"},
+		{name: "new volume, invalid snapshot", snapHost: "data", err: `tagged for host "data"`},
+		{
+			name:      "existing volume",
+			snapHost:  "coder",
+			volExists: true,
+			warning:   "coder-var already exists, so snapshot snap-0123 isn't restored unless the volume is replaced",
+		},
+		{
+			name:      "existing volume, invalid snapshot",
+			snapHost:  "data",
+			volExists: true,
+			warning:   `not "coder"; coder-var already exists, so it isn't restored`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var volIDs []resource.PropertyValue
+			if tt.volExists {
+				volIDs = append(volIDs, resource.NewStringProperty("vol-0123"))
+			}
+			mocks := &pulumitest.Mocks{Calls: map[string]func(resource.PropertyMap) resource.PropertyMap{
+				getSnapshot: func(resource.PropertyMap) resource.PropertyMap {
+					return resource.PropertyMap{
+						"state":      resource.NewStringProperty("completed"),
+						"volumeSize": resource.NewNumberProperty(64),
+						"tags": resource.NewObjectProperty(resource.PropertyMap{
+							HostTag: resource.NewStringProperty(tt.snapHost),
+						}),
+					}
+				},
+				getEbsVolumes: func(resource.PropertyMap) resource.PropertyMap {
+					return resource.PropertyMap{"ids": resource.NewArrayProperty(volIDs)}
+				},
+			}}
+			var warning string
+			err := pulumitest.Run(mocks, nil, func(ctx *pulumi.Context) error {
+				var err error
+				warning, err = checkRestore(ctx, "coder-var", "coder", "snap-0123", 64)
+				return err
+			})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error is %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.warning == "" && warning != "" || !strings.Contains(warning, tt.warning) {
+				t.Errorf("warning is %q, want %q", warning, tt.warning)
+			}
+			if !mocks.Invoked(getEbsVolumes) {
+				t.Error("didn't look up the volume")
+			}
+		})
+	}
+}
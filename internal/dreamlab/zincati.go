@@ -0,0 +1,113 @@
+package dreamlab
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Zincati update strategies
+const (
+	UpdatesImmediate = "immediate"
+	UpdatesPeriodic  = "periodic"
+	UpdatesFleetLock = "fleet_lock"
+)
+
+var weekdays = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// UpdatesConfig configures when Zincati may reboot a Fedora CoreOS host to
+// apply updates. The zero value keeps Zincati's default (immediate).
+type UpdatesConfig struct {
+	Strategy string `json:"strategy"`
+	// periodic strategy: reboot windows and their time zone (default UTC)
+	Windows  []UpdateWindow `json:"windows"`
+	TimeZone string         `json:"timeZone"`
+	// fleet_lock strategy: lock server base URL
+	FleetLockURL string `json:"fleetLockURL"`
+}
+
+// UpdateWindow is a periodic reboot window
+type UpdateWindow struct {
+	Days          []string `json:"days"`      // Mon, Tue, ...
+	StartTime     string   `json:"startTime"` // HH:MM, 24-hour
+	LengthMinutes int      `json:"lengthMinutes"`
+}
+
+func (c *UpdatesConfig) validate() error {
+	switch c.Strategy {
+	case "", UpdatesImmediate:
+		return nil
+	case UpdatesPeriodic:
+		if len(c.Windows) == 0 {
+			return fmt.Errorf("periodic updates require at least one window")
+		}
+		if c.TimeZone != "" {
+			if _, err := time.LoadLocation(c.TimeZone); err != nil {
+				return fmt.Errorf("periodic updates: invalid time zone %q", c.TimeZone)
+			}
+		}
+		for i, w := range c.Windows {
+			if err := w.validate(); err != nil {
+				return fmt.Errorf("periodic updates window %d: %w", i, err)
+			}
+		}
+		return nil
+	case UpdatesFleetLock:
+		u, err := url.Parse(c.FleetLockURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("fleet_lock updates: invalid lock server URL %q", c.FleetLockURL)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown update strategy %q", c.Strategy)
+}
+
+func (w UpdateWindow) validate() error {
+	if len(w.Days) == 0 {
+		return fmt.Errorf("no days")
+	}
+	for _, d := range w.Days {
+		if !slices.Contains(weekdays, d) {
+			return fmt.Errorf("invalid day %q (use %s)", d, strings.Join(weekdays, ", "))
+		}
+	}
+	if _, err := time.Parse("15:04", w.StartTime); err != nil || len(w.StartTime) != 5 {
+		return fmt.Errorf("invalid start time %q (use HH:MM)", w.StartTime)
+	}
+	if w.LengthMinutes < 1 || w.LengthMinutes > 24*60 {
+		return fmt.Errorf("length must be between 1 and 1440 minutes")
+	}
+	return nil
+}
+
+// ZincatiTOML returns the Zincati config.d fragment for the update
+// strategy, or an empty string if Zincati's default should be used.
+func (c *UpdatesConfig) ZincatiTOML() (string, error) {
+	if c == nil || c.Strategy == "" {
+		return "", nil
+	}
+	if err := c.validate(); err != nil {
+		return "", err
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "[updates]\nstrategy = %q\n", c.Strategy)
+	switch c.Strategy {
+	case UpdatesPeriodic:
+		if c.TimeZone != "" {
+			fmt.Fprintf(b, "\n[updates.periodic]\ntime_zone = %q\n", c.TimeZone)
+		}
+		for _, w := range c.Windows {
+			days := make([]string, len(w.Days))
+			for i, d := range w.Days {
+				days[i] = fmt.Sprintf("%q", d)
+			}
+			fmt.Fprintf(b, "\n[[updates.periodic.window]]\ndays = [ %s ]\nstart_time = %q\nlength_minutes = %d\n",
+				strings.Join(days, ", "), w.StartTime, w.LengthMinutes)
+		}
+	case UpdatesFleetLock:
+		fmt.Fprintf(b, "\n[updates.fleet_lock]\nbase_url = %q\n", c.FleetLockURL)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,143 @@
+package dreamlab
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestZincatiTOML(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *UpdatesConfig
+		want    string
+		wantErr string
+	}{
+		{name: "nil config", cfg: nil, want: ""},
+		{name: "default strategy", cfg: &UpdatesConfig{}, want: ""},
+		{
+			name: "immediate",
+			cfg:  &UpdatesConfig{Strategy: UpdatesImmediate},
+			want: "[updates]\nstrategy = \"immediate\"\n",
+		},
+		{
+			name: "periodic",
+			cfg: &UpdatesConfig{
+				Strategy: UpdatesPeriodic,
+				TimeZone: "America/Los_Angeles",
+				Windows: []UpdateWindow{
+					{Days: []string{"Sat", "Sun"}, StartTime: "03:30", LengthMinutes: 60},
+					{Days: []string{"Wed"}, StartTime: "22:00", LengthMinutes: 120},
+				},
+			},
+			want: `[updates]
+strategy = "periodic"
+
+[updates.periodic]
+time_zone = "America/Los_Angeles"
+
+[[updates.periodic.window]]
+days = [ "Sat", "Sun" ]
+start_time = "03:30"
+length_minutes = 60
+
+[[updates.periodic.window]]
+days = [ "Wed" ]
+start_time = "22:00"
+length_minutes = 120
+`,
+		},
+		{
+			name: "periodic in UTC",
+			cfg: &UpdatesConfig{
+				Strategy: UpdatesPeriodic,
+				Windows:  []UpdateWindow{{Days: []string{"Mon"}, StartTime: "04:00", LengthMinutes: 30}},
+			},
+			want: `[updates]
+strategy = "periodic"
+
+[[updates.periodic.window]]
+days = [ "Mon" ]
+start_time = "04:00"
+length_minutes = 30
+`,
+		},
+		{
+			name: "fleet_lock",
+			cfg:  &UpdatesConfig{Strategy: UpdatesFleetLock, FleetLockURL: "https://fleetlock.example.edu"},
+			want: `[updates]
+strategy = "fleet_lock"
+
+[updates.fleet_lock]
+base_url = "https://fleetlock.example.edu"
+`,
+		},
+		{
+			name:    "fleet_lock without url",
+			cfg:     &UpdatesConfig{Strategy: UpdatesFleetLock},
+			wantErr: "invalid lock server URL",
+		},
+		{
+			name:    "periodic without windows",
+			cfg:     &UpdatesConfig{Strategy: UpdatesPeriodic},
+			wantErr: "at least one window",
+		},
+		{
+			name: "invalid day",
+			cfg: &UpdatesConfig{
+				Strategy: UpdatesPeriodic,
+				Windows:  []UpdateWindow{{Days: []string{"Monday"}, StartTime: "04:00", LengthMinutes: 30}},
+			},
+			wantErr: `window 0: invalid day "Monday"`,
+		},
+		{
+			name: "invalid start time",
+			cfg: &UpdatesConfig{
+				Strategy: UpdatesPeriodic,
+				Windows: []UpdateWindow{
+					{Days: []string{"Mon"}, StartTime: "04:00", LengthMinutes: 30},
+					{Days: []string{"Tue"}, StartTime: "4:00", LengthMinutes: 30},
+				},
+			},
+			wantErr: `window 1: invalid start time "4:00"`,
+		},
+		{
+			name: "window too long",
+			cfg: &UpdatesConfig{
+				Strategy: UpdatesPeriodic,
+				Windows:  []UpdateWindow{{Days: []string{"Mon"}, StartTime: "04:00", LengthMinutes: 24*60 + 1}},
+			},
+			wantErr: "length must be between",
+		},
+		{
+			name: "invalid time zone",
+			cfg: &UpdatesConfig{
+				Strategy: UpdatesPeriodic,
+				TimeZone: "Pacific",
+				Windows:  []UpdateWindow{{Days: []string{"Mon"}, StartTime: "04:00", LengthMinutes: 30}},
+			},
+			wantErr: `invalid time zone "Pacific"`,
+		},
+		{
+			name:    "unknown strategy",
+			cfg:     &UpdatesConfig{Strategy: "weekly"},
+			wantErr: `unknown update strategy "weekly"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cfg.ZincatiTOML()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ZincatiTOML() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ZincatiTOML() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"dreamlab/coder"
 	"dreamlab/internal/dreamlab"
 
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
@@ -19,28 +18,38 @@ func main() {
 			return err
 		}
 		stackConfig := config.New(ctx, "")
-		// coder.dreamlab.ucsb.edu
-		if err := coder.New(ctx, "coder", &coder.Config{
-			Hostname:     "coder",
-			VPC:          vpc,
-			DNS:          dns,
-			InstanceAMI:  stackConfig.Get("coder_instance_ami"),
-			InstanceType: stackConfig.Get("coder_instance_type"),
-		}); err != nil {
+		var backups dreamlab.BackupConfig
+		if err := stackConfig.GetObject("backups", &backups); err != nil {
 			return err
 		}
+		// customer managed key for host volumes
+		var volumeKeyArn pulumi.StringInput
+		if stackConfig.GetBool("ebs_kms_key") {
+			key, err := dreamlab.NewKMSKey(ctx, "dreamlab-ebs", "dreamlab host volumes")
+			if err != nil {
+				return err
+			}
+			volumeKeyArn = key.Arn
+		}
+		// traefik settings shared by every host
+		var acme dreamlab.ACMEConfig
+		if err := stackConfig.GetObject("acme", &acme); err != nil {
+			return err
+		}
+		var traefik dreamlab.TraefikConfig
+		if err := stackConfig.GetObject("traefik", &traefik); err != nil {
+			return err
+		}
+		traefik.ACME = &acme
 
-		// //data.dreamlab.ucsb.edu runs ocfl-server
-		// if err := ocfl.New(ctx, "data", &ocfl.Config{
-		// 	Hostname:     "data",
-		// 	VPC:          vpc,
-		// 	DNS:          dns,
-		// 	InstanceAMI:  stackConfig.Get("coder_instance_ami"),
-		// 	InstanceType: stackConfig.Get("coder_instance_type"),
-		// }); err != nil {
-		// 	return err
-		// }
-
-		return nil
+		// coder.dreamlab.ucsb.edu, data.dreamlab.ucsb.edu, ...
+		return newServices(ctx, &stack{
+			config:       stackConfig,
+			vpc:          vpc,
+			dns:          dns,
+			backups:      &backups,
+			traefik:      &traefik,
+			volumeKeyArn: volumeKeyArn,
+		})
 	})
 }
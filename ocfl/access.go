@@ -0,0 +1,81 @@
+package ocfl
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// accessHeader is the forward-auth response header with the signed-in
+// user's email, which traefik passes to the ocfl server.
+const accessHeader = "Remote-Email"
+
+var (
+	// users sign in with their campus Google account
+	accessEmail = regexp.MustCompile(`^[a-z0-9._%+-]+@ucsb\.edu$`)
+	accessGroup = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+)
+
+// AccessConfig lists who can sign in to the data service and what they can
+// do. Read and Write entries are emails or the names of Groups; writers can
+// also read. It is read from the stack's "data_access" config object.
+type AccessConfig struct {
+	Groups map[string][]string `json:"groups"` // group name: emails
+	Read   []string            `json:"read"`
+	Write  []string            `json:"write"`
+}
+
+func (c *AccessConfig) validate() error {
+	if c == nil {
+		return fmt.Errorf("access: no users can sign in")
+	}
+	for name, emails := range c.Groups {
+		if !accessGroup.MatchString(name) {
+			return fmt.Errorf("access: invalid group name %q", name)
+		}
+		for _, email := range emails {
+			if !accessEmail.MatchString(email) {
+				return fmt.Errorf("access: group %s: invalid email %q (must be a lowercase ucsb.edu address)", name, email)
+			}
+		}
+	}
+	for _, entry := range slices.Concat(c.Read, c.Write) {
+		if strings.Contains(entry, "@") {
+			if !accessEmail.MatchString(entry) {
+				return fmt.Errorf("access: invalid email %q (must be a lowercase ucsb.edu address)", entry)
+			}
+			continue
+		}
+		if _, ok := c.Groups[entry]; !ok {
+			return fmt.Errorf("access: %q isn't an email or a group", entry)
+		}
+	}
+	if len(c.writers()) == 0 {
+		return fmt.Errorf("access: write has no users")
+	}
+	return nil
+}
+
+// members returns the sorted emails of the entries, with groups expanded.
+func (c *AccessConfig) members(entries []string) []string {
+	var emails []string
+	for _, entry := range entries {
+		if strings.Contains(entry, "@") {
+			emails = append(emails, entry)
+			continue
+		}
+		emails = append(emails, c.Groups[entry]...)
+	}
+	slices.Sort(emails)
+	return slices.Compact(emails)
+}
+
+// readers returns the emails of users who can read, including writers.
+func (c *AccessConfig) readers() []string {
+	return c.members(slices.Concat(c.Read, c.Write))
+}
+
+func (c *AccessConfig) writers() []string {
+	return c.members(c.Write)
+}
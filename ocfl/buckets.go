@@ -0,0 +1,259 @@
+package ocfl
+
+import (
+	"dreamlab/internal/dreamlab/iam"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/s3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	defaultPublicBucket     = "dreamlab-public"
+	defaultRestrictedBucket = "dreamlab-restricted"
+	defaultPublicPrefix     = "ocfl/"
+	defaultNoncurrentDays   = 90
+	// incomplete multipart uploads are aborted after this many days
+	abortMultipartDays = 7
+)
+
+var bucketName = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// BucketsConfig configures the buckets that hold OCFL content. It is read
+// from the stack's "data_buckets" config object.
+type BucketsConfig struct {
+	Public     string `json:"public"`     // default: dreamlab-public
+	Restricted string `json:"restricted"` // default: dreamlab-restricted
+	// PublicPrefix is the prefix of the public bucket that anyone can read
+	// (default: ocfl/). The OCFL storage root is kept there.
+	PublicPrefix string `json:"publicPrefix"`
+	// NoncurrentDays is how long overwritten and deleted object versions are
+	// kept (default: 90).
+	NoncurrentDays int `json:"noncurrentDays"`
+	// Import adopts existing buckets into the stack instead of creating
+	// them. It can be unset after the buckets are imported.
+	Import bool `json:"import"`
+	// Tiering moves content in the storage root to cheaper storage classes
+	Tiering *TieringConfig `json:"tiering"`
+	// Replication copies the storage root to a bucket in another region
+	Replication *ReplicationConfig `json:"replication"`
+}
+
+func (c *BucketsConfig) publicBucket() string {
+	if c == nil || c.Public == "" {
+		return defaultPublicBucket
+	}
+	return c.Public
+}
+
+func (c *BucketsConfig) restrictedBucket() string {
+	if c == nil || c.Restricted == "" {
+		return defaultRestrictedBucket
+	}
+	return c.Restricted
+}
+
+func (c *BucketsConfig) publicPrefix() string {
+	if c == nil || c.PublicPrefix == "" {
+		return defaultPublicPrefix
+	}
+	return strings.TrimSuffix(c.PublicPrefix, "/") + "/"
+}
+
+func (c *BucketsConfig) noncurrentDays() int {
+	if c == nil || c.NoncurrentDays == 0 {
+		return defaultNoncurrentDays
+	}
+	return c.NoncurrentDays
+}
+
+// StorageRoot returns the s3:// URL of the OCFL storage root in the public
+// bucket.
+func (c *BucketsConfig) StorageRoot() string {
+	return "s3://" + c.publicBucket() + "/" + strings.TrimSuffix(c.publicPrefix(), "/")
+}
+
+func (c *BucketsConfig) validate() error {
+	for _, name := range []string{c.publicBucket(), c.restrictedBucket()} {
+		if !bucketName.MatchString(name) {
+			return fmt.Errorf("buckets: invalid bucket name %q", name)
+		}
+	}
+	if c.publicBucket() == c.restrictedBucket() {
+		return fmt.Errorf("buckets: public and restricted buckets are both %q", c.publicBucket())
+	}
+	if strings.HasPrefix(c.publicPrefix(), "/") {
+		return fmt.Errorf("buckets: invalid public prefix %q", c.PublicPrefix)
+	}
+	if c.noncurrentDays() < 1 {
+		return fmt.Errorf("buckets: noncurrentDays must be at least 1")
+	}
+	if err := c.Tiering.validate(); err != nil {
+		return fmt.Errorf("buckets: %w", err)
+	}
+	if err := c.Replication.validate(c); err != nil {
+		return fmt.Errorf("buckets: %w", err)
+	}
+	return nil
+}
+
+// newBuckets creates (or imports) the public and restricted buckets. Both
+// are versioned with SSE-S3 encryption. The restricted bucket blocks all
+// public access; the public bucket blocks public ACLs, and its bucket
+// policy allows anyone to read objects under the public prefix. If
+// configured, content under the public prefix is tiered, replicated and
+// served by CloudFront.
+func newBuckets(ctx *pulumi.Context, resource string, ocflConfig *Config) error {
+	cfg := ocflConfig.Buckets
+	if err := cfg.validate(); err != nil {
+		return fmt.Errorf("%s: %w", resource, err)
+	}
+	tiering := cfg.Tiering.rules(cfg.publicPrefix())
+	public, err := newBucket(ctx, resource+"-bucket-public", cfg, bucketArgs{
+		name:   cfg.publicBucket(),
+		adopt:  cfg != nil && cfg.Import,
+		public: true,
+		rules:  tiering,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = newBucket(ctx, resource+"-bucket-restricted", cfg, bucketArgs{
+		name:  cfg.restrictedBucket(),
+		adopt: cfg != nil && cfg.Import,
+	})
+	if err != nil {
+		return err
+	}
+	cdnStmts, err := newCDN(ctx, resource, ocflConfig, public)
+	if err != nil {
+		return err
+	}
+	prefix := cfg.publicPrefix()
+	_, err = s3.NewBucketPolicy(ctx, resource+"-bucket-public-policy", &s3.BucketPolicyArgs{
+		Bucket: public.ID(),
+		Policy: iam.Document(append([]*iam.Statement{
+			iam.Allow("s3:GetObject").
+				WithSid("PublicRead").
+				ToAnyone().
+				On(pulumi.Sprintf("%s/%s*", public.Arn, prefix)),
+			iam.Allow("s3:ListBucket").
+				WithSid("PublicList").
+				ToAnyone().
+				On(public.Arn).
+				When(iam.StringLike("s3:prefix", prefix+"*")),
+		}, cdnStmts...)...),
+	}, pulumi.DependsOn([]pulumi.Resource{public.accessBlock}))
+	if err != nil {
+		return err
+	}
+	if cfg.Replication == nil {
+		return nil
+	}
+	return newReplication(ctx, resource, cfg, public, tiering)
+}
+
+// bucket is a bucket and the resources that configure it
+type bucket struct {
+	*s3.BucketV2
+	versioning  *s3.BucketVersioningV2
+	accessBlock *s3.BucketPublicAccessBlock
+}
+
+// bucketArgs are the differences between the buckets newBucket creates
+type bucketArgs struct {
+	name   string
+	adopt  bool // import an existing bucket
+	public bool // allow a public bucket policy
+	// SSE-KMS with this key instead of SSE-S3
+	kmsKeyArn pulumi.StringInput
+	// lifecycle rules in addition to the noncurrent version and incomplete
+	// upload rules
+	rules s3.BucketLifecycleConfigurationV2RuleArray
+	// options for every resource, e.g. the provider for another region
+	opts []pulumi.ResourceOption
+}
+
+// newBucket creates a versioned, encrypted bucket that blocks public access
+// (except for a public bucket policy if args.public is set), with lifecycle
+// rules for noncurrent versions and incomplete multipart uploads. The
+// bucket's resources are returned so others can depend on them.
+func newBucket(ctx *pulumi.Context, resource string, cfg *BucketsConfig, args bucketArgs) (*bucket, error) {
+	opts := append([]pulumi.ResourceOption{pulumi.Protect(true)}, args.opts...)
+	if args.adopt {
+		opts = append(opts, pulumi.Import(pulumi.ID(args.name)))
+	}
+	b, err := s3.NewBucketV2(ctx, resource, &s3.BucketV2Args{
+		Bucket: pulumi.String(args.name),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	block, err := s3.NewBucketPublicAccessBlock(ctx, resource+"-access-block", &s3.BucketPublicAccessBlockArgs{
+		Bucket:                b.ID(),
+		BlockPublicAcls:       pulumi.Bool(true),
+		IgnorePublicAcls:      pulumi.Bool(true),
+		BlockPublicPolicy:     pulumi.Bool(!args.public),
+		RestrictPublicBuckets: pulumi.Bool(!args.public),
+	}, args.opts...)
+	if err != nil {
+		return nil, err
+	}
+	versioning, err := s3.NewBucketVersioningV2(ctx, resource+"-versioning", &s3.BucketVersioningV2Args{
+		Bucket: b.ID(),
+		VersioningConfiguration: &s3.BucketVersioningV2VersioningConfigurationArgs{
+			Status: pulumi.String("Enabled"),
+		},
+	}, args.opts...)
+	if err != nil {
+		return nil, err
+	}
+	sse := &s3.BucketServerSideEncryptionConfigurationV2RuleApplyServerSideEncryptionByDefaultArgs{
+		SseAlgorithm: pulumi.String("AES256"),
+	}
+	if args.kmsKeyArn != nil {
+		sse = &s3.BucketServerSideEncryptionConfigurationV2RuleApplyServerSideEncryptionByDefaultArgs{
+			SseAlgorithm:   pulumi.String("aws:kms"),
+			KmsMasterKeyId: args.kmsKeyArn,
+		}
+	}
+	_, err = s3.NewBucketServerSideEncryptionConfigurationV2(ctx, resource+"-encryption", &s3.BucketServerSideEncryptionConfigurationV2Args{
+		Bucket: b.ID(),
+		Rules: s3.BucketServerSideEncryptionConfigurationV2RuleArray{
+			&s3.BucketServerSideEncryptionConfigurationV2RuleArgs{
+				ApplyServerSideEncryptionByDefault: sse,
+				BucketKeyEnabled:                   pulumi.Bool(args.kmsKeyArn != nil),
+			},
+		},
+	}, args.opts...)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s3.NewBucketLifecycleConfigurationV2(ctx, resource+"-lifecycle", &s3.BucketLifecycleConfigurationV2Args{
+		Bucket: b.ID(),
+		Rules: append(s3.BucketLifecycleConfigurationV2RuleArray{
+			&s3.BucketLifecycleConfigurationV2RuleArgs{
+				Id:     pulumi.String("expire-noncurrent-versions"),
+				Status: pulumi.String("Enabled"),
+				Filter: &s3.BucketLifecycleConfigurationV2RuleFilterArgs{},
+				NoncurrentVersionExpiration: &s3.BucketLifecycleConfigurationV2RuleNoncurrentVersionExpirationArgs{
+					NoncurrentDays: pulumi.Int(cfg.noncurrentDays()),
+				},
+			},
+			&s3.BucketLifecycleConfigurationV2RuleArgs{
+				Id:     pulumi.String("abort-incomplete-uploads"),
+				Status: pulumi.String("Enabled"),
+				Filter: &s3.BucketLifecycleConfigurationV2RuleFilterArgs{},
+				AbortIncompleteMultipartUpload: &s3.BucketLifecycleConfigurationV2RuleAbortIncompleteMultipartUploadArgs{
+					DaysAfterInitiation: pulumi.Int(abortMultipartDays),
+				},
+			},
+		}, args.rules...),
+	}, append(args.opts, pulumi.DependsOn([]pulumi.Resource{versioning}))...)
+	if err != nil {
+		return nil, err
+	}
+	return &bucket{BucketV2: b, versioning: versioning, accessBlock: block}, nil
+}
@@ -0,0 +1,265 @@
+package ocfl
+
+import (
+	"dreamlab/internal/dreamlab/pulumitest"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// runBuckets runs newBuckets under mocks, in us-west-2.
+func runBuckets(t *testing.T, cfg *BucketsConfig) *pulumitest.Mocks {
+	t.Helper()
+	mocks := &pulumitest.Mocks{}
+	err := pulumitest.Run(mocks, map[string]string{"aws:region": "us-west-2"}, func(ctx *pulumi.Context) error {
+		return newBuckets(ctx, "data", &Config{Buckets: cfg})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mocks
+}
+
+func TestNewBucketsImport(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *BucketsConfig
+		// bucket resource: imported bucket name, or "" if it's created
+		want map[string]string
+	}{
+		{
+			name: "created",
+			cfg:  &BucketsConfig{},
+			want: map[string]string{
+				"data-bucket-public":     "",
+				"data-bucket-restricted": "",
+			},
+		},
+		{
+			name: "imported",
+			cfg:  &BucketsConfig{Import: true},
+			want: map[string]string{
+				"data-bucket-public":     "dreamlab-public",
+				"data-bucket-restricted": "dreamlab-restricted",
+			},
+		},
+		{
+			name: "imported with a new replica",
+			cfg: &BucketsConfig{
+				Import:      true,
+				Replication: &ReplicationConfig{Region: "us-east-2"},
+			},
+			want: map[string]string{
+				"data-bucket-public":     "dreamlab-public",
+				"data-bucket-restricted": "dreamlab-restricted",
+				"data-bucket-replica":    "",
+			},
+		},
+		{
+			name: "existing replica",
+			cfg: &BucketsConfig{
+				Replication: &ReplicationConfig{Region: "us-east-2", Bucket: "dreamlab-dr", Import: true},
+			},
+			want: map[string]string{
+				"data-bucket-public":     "",
+				"data-bucket-restricted": "",
+				"data-bucket-replica":    "dreamlab-dr",
+			},
+		},
+		{
+			name: "all imported",
+			cfg: &BucketsConfig{
+				Import:      true,
+				Replication: &ReplicationConfig{Region: "us-east-2", Import: true},
+			},
+			want: map[string]string{
+				"data-bucket-public":     "dreamlab-public",
+				"data-bucket-restricted": "dreamlab-restricted",
+				"data-bucket-replica":    "dreamlab-public-replica",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mocks := runBuckets(t, tt.cfg)
+			for name, id := range tt.want {
+				r := mocks.Find(name)
+				if r == nil {
+					t.Errorf("%s wasn't registered", name)
+					continue
+				}
+				if r.ID != id {
+					t.Errorf("%s: import id = %q, want %q", name, r.ID, id)
+				}
+			}
+			if _, ok := tt.want["data-bucket-replica"]; !ok && mocks.Find("data-bucket-replica") != nil {
+				t.Error("replica bucket registered without replication")
+			}
+		})
+	}
+}
+
+func TestNewBucketsReplicationRegion(t *testing.T) {
+	err := pulumitest.Run(&pulumitest.Mocks{}, map[string]string{"aws:region": "us-west-2"}, func(ctx *pulumi.Context) error {
+		return newBuckets(ctx, "data", &Config{Buckets: &BucketsConfig{
+			Replication: &ReplicationConfig{Region: "us-west-2"},
+		}})
+	})
+	if err == nil {
+		t.Fatal("replication to the stack's region didn't fail")
+	}
+}
+
+func TestTieringValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *TieringConfig
+		err  string
+	}{
+		{name: "not configured"},
+		{name: "glacier", cfg: &TieringConfig{GlacierDays: 180}},
+		{name: "both", cfg: &TieringConfig{MinSize: minTieringMinSize, IntelligentTieringDays: 30, GlacierDays: 180}},
+		{name: "small minSize", cfg: &TieringConfig{MinSize: 4096, GlacierDays: 180}, err: "minSize must be at least 131072 bytes"},
+		{name: "negative minSize", cfg: &TieringConfig{MinSize: -1, GlacierDays: 180}, err: "minSize must be at least"},
+		{name: "negative days", cfg: &TieringConfig{IntelligentTieringDays: -1}, err: "days can't be negative"},
+		{name: "no days", cfg: &TieringConfig{MinSize: 1 << 20}, err: "set intelligentTieringDays or glacierDays"},
+		{name: "glacier first", cfg: &TieringConfig{IntelligentTieringDays: 90, GlacierDays: 90}, err: "glacierDays must be after intelligentTieringDays"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, tt.cfg.validate(), tt.err)
+		})
+	}
+}
+
+func TestReplicationValidate(t *testing.T) {
+	buckets := &BucketsConfig{Public: "lab-public", Restricted: "lab-restricted"}
+	tests := []struct {
+		name string
+		cfg  *ReplicationConfig
+		err  string
+	}{
+		{name: "not configured"},
+		{name: "defaults", cfg: &ReplicationConfig{Region: "us-east-2"}},
+		{name: "no region", cfg: &ReplicationConfig{}, err: "region is required"},
+		{name: "invalid bucket", cfg: &ReplicationConfig{Region: "us-east-2", Bucket: "Lab_Replica"}, err: `invalid bucket name "Lab_Replica"`},
+		{name: "public bucket", cfg: &ReplicationConfig{Region: "us-east-2", Bucket: "lab-public"}, err: `bucket "lab-public" is already used`},
+		{name: "restricted bucket", cfg: &ReplicationConfig{Region: "us-east-2", Bucket: "lab-restricted"}, err: `bucket "lab-restricted" is already used`},
+		{name: "storage class", cfg: &ReplicationConfig{Region: "us-east-2", StorageClass: "REDUCED_REDUNDANCY"}, err: `invalid storage class "REDUCED_REDUNDANCY"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, tt.cfg.validate(buckets), tt.err)
+		})
+	}
+}
+
+func checkErr(t *testing.T, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("unexpected error: %v", err)
+	case want != "" && (err == nil || !strings.Contains(err.Error(), want)):
+		t.Errorf("error is %v, want %q", err, want)
+	}
+}
+
+// inputs returns the resource's inputs as plain values
+func inputs(t *testing.T, mocks *pulumitest.Mocks, name string) map[string]any {
+	t.Helper()
+	r := mocks.Find(name)
+	if r == nil {
+		t.Fatalf("%s wasn't registered", name)
+	}
+	return r.Inputs.Mappable()
+}
+
+// Content under the public prefix is tiered in the public and replica
+// buckets, and replicated to the replica bucket with its KMS key.
+func TestNewBucketsTieringReplication(t *testing.T) {
+	mocks := runBuckets(t, &BucketsConfig{
+		Tiering:     &TieringConfig{IntelligentTieringDays: 30, GlacierDays: 180},
+		Replication: &ReplicationConfig{Region: "us-east-2", StorageClass: "GLACIER_IR"},
+	})
+
+	wantTiering := map[string]any{
+		"id":     "tier-content",
+		"status": "Enabled",
+		"filter": map[string]any{
+			"and": map[string]any{"prefix": "ocfl/", "objectSizeGreaterThan": float64(1 << 20)},
+		},
+		"transitions": []any{
+			map[string]any{"days": float64(30), "storageClass": "INTELLIGENT_TIERING"},
+			map[string]any{"days": float64(180), "storageClass": "GLACIER_IR"},
+		},
+	}
+	for _, name := range []string{"data-bucket-public-lifecycle", "data-bucket-replica-lifecycle"} {
+		rules := inputs(t, mocks, name)["rules"].([]any)
+		i := slices.IndexFunc(rules, func(r any) bool { return r.(map[string]any)["id"] == "tier-content" })
+		if i < 0 {
+			t.Errorf("%s has no tier-content rule", name)
+			continue
+		}
+		if got := rules[i]; !reflect.DeepEqual(got, wantTiering) {
+			t.Errorf("%s tier-content rule is %v, want %v", name, got, wantTiering)
+		}
+	}
+	if rules := inputs(t, mocks, "data-bucket-restricted-lifecycle")["rules"].([]any); len(rules) != 2 {
+		t.Errorf("restricted bucket has %d lifecycle rules, want 2", len(rules))
+	}
+
+	repl := inputs(t, mocks, "data-bucket-public-replication")
+	rules := repl["rules"].([]any)
+	if len(rules) != 1 {
+		t.Fatalf("%d replication rules, want 1", len(rules))
+	}
+	wantRule := map[string]any{
+		"id":                      "replicate-storage-root",
+		"status":                  "Enabled",
+		"filter":                  map[string]any{"prefix": "ocfl/"},
+		"deleteMarkerReplication": map[string]any{"status": "Disabled"},
+		"destination": map[string]any{
+			"bucket":       "arn:mock:data-bucket-replica",
+			"storageClass": "GLACIER_IR",
+			"encryptionConfiguration": map[string]any{
+				"replicaKmsKeyId": "arn:mock:data-replica-key",
+			},
+		},
+	}
+	if got := rules[0]; !reflect.DeepEqual(got, wantRule) {
+		t.Errorf("replication rule is %v, want %v", got, wantRule)
+	}
+	if got := repl["bucket"]; got != "data-bucket-public_id" {
+		t.Errorf("replication bucket is %v", got)
+	}
+
+	var policy struct {
+		Statement []struct {
+			Sid      string
+			Action   []string
+			Resource []string
+		}
+	}
+	if err := json.Unmarshal([]byte(inputs(t, mocks, "data-replication-role-policy")["policy"].(string)), &policy); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"ReplicationSource":        {"arn:mock:data-bucket-public"},
+		"ReplicationSourceObjects": {"arn:mock:data-bucket-public/ocfl/*"},
+		"ReplicationDestination":   {"arn:mock:data-bucket-replica/ocfl/*"},
+		"ReplicaKey":               {"arn:mock:data-replica-key"},
+	}
+	for _, s := range policy.Statement {
+		if !slices.Equal(s.Resource, want[s.Sid]) {
+			t.Errorf("%s: resources are %v, want %v", s.Sid, s.Resource, want[s.Sid])
+		}
+		delete(want, s.Sid)
+	}
+	for sid := range want {
+		t.Errorf("no %s statement", sid)
+	}
+}
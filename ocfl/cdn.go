@@ -0,0 +1,234 @@
+package ocfl
+
+import (
+	"dreamlab/internal/dreamlab/iam"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/acm"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudfront"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/route53"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	defaultCDNHostname   = "files"
+	defaultCDNPriceClass = "PriceClass_100"
+	// cloudfront certificates must be in us-east-1
+	cdnCertificateRegion = "us-east-1"
+	// content files are cached for a year; inventories, sidecars and other
+	// files that change when objects are updated, for a minute.
+	contentTTL  = 365 * 24 * 60 * 60
+	metadataTTL = 60
+	// version content files, assuming objects use the default content
+	// directory. The distribution's paths are relative to the storage root.
+	contentPathPattern = "*/content/*"
+)
+
+var (
+	cdnHostname     = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	cdnPriceClasses = []string{"PriceClass_100", "PriceClass_200", "PriceClass_All"}
+)
+
+// CDNConfig configures a CloudFront distribution of the OCFL storage root in
+// the public bucket. It is read from the stack's "data_cdn" config object.
+type CDNConfig struct {
+	Enabled bool `json:"enabled"`
+	// Hostname in the lab's zone (default: files)
+	Hostname string `json:"hostname"`
+	// PriceClass limits the edge locations (default: PriceClass_100)
+	PriceClass string `json:"priceClass"`
+}
+
+func (c *CDNConfig) hostname() string {
+	if c.Hostname == "" {
+		return defaultCDNHostname
+	}
+	return c.Hostname
+}
+
+func (c *CDNConfig) priceClass() string {
+	if c.PriceClass == "" {
+		return defaultCDNPriceClass
+	}
+	return c.PriceClass
+}
+
+func (c *CDNConfig) validate() error {
+	if !cdnHostname.MatchString(c.hostname()) {
+		return fmt.Errorf("cdn: invalid hostname %q", c.hostname())
+	}
+	if !slices.Contains(cdnPriceClasses, c.priceClass()) {
+		return fmt.Errorf("cdn: invalid price class %q (use %s)", c.PriceClass, strings.Join(cdnPriceClasses, ", "))
+	}
+	return nil
+}
+
+// newCDN creates a CloudFront distribution of the public bucket's storage
+// root, with a DNS-validated certificate and alias records for
+// <hostname>.<domain>. CloudFront reads the bucket with origin access
+// control; newCDN returns the bucket policy statement that allows it. It
+// does nothing if the CDN isn't enabled.
+func newCDN(ctx *pulumi.Context, resource string, ocflConfig *Config, public *bucket) ([]*iam.Statement, error) {
+	cfg := ocflConfig.CDN
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", resource, err)
+	}
+	domain := cfg.hostname() + "." + ocflConfig.DNS.Domain()
+	provider, err := aws.NewProvider(ctx, resource+"-cdn-provider", &aws.ProviderArgs{
+		Region: pulumi.String(cdnCertificateRegion),
+	})
+	if err != nil {
+		return nil, err
+	}
+	cert, err := acm.NewCertificate(ctx, resource+"-cdn-cert", &acm.CertificateArgs{
+		DomainName:       pulumi.String(domain),
+		ValidationMethod: pulumi.String("DNS"),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, err
+	}
+	validation := cert.DomainValidationOptions.Index(pulumi.Int(0))
+	validationRecord, err := route53.NewRecord(ctx, resource+"-dns-cdn-validation", &route53.RecordArgs{
+		Name:           validation.ResourceRecordName().Elem(),
+		ZoneId:         ocflConfig.DNS.ZoneId,
+		Type:           validation.ResourceRecordType().Elem(),
+		Records:        pulumi.StringArray{validation.ResourceRecordValue().Elem()},
+		Ttl:            pulumi.Int(600),
+		AllowOverwrite: pulumi.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	certValidation, err := acm.NewCertificateValidation(ctx, resource+"-cdn-cert-validation", &acm.CertificateValidationArgs{
+		CertificateArn:        cert.Arn,
+		ValidationRecordFqdns: pulumi.StringArray{validationRecord.Fqdn},
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, err
+	}
+	oac, err := cloudfront.NewOriginAccessControl(ctx, resource+"-cdn-oac", &cloudfront.OriginAccessControlArgs{
+		Name:                          pulumi.String(resource + "-cdn-oac"),
+		Description:                   pulumi.String("OCFL storage root in " + ocflConfig.Buckets.publicBucket()),
+		OriginAccessControlOriginType: pulumi.String("s3"),
+		SigningBehavior:               pulumi.String("always"),
+		SigningProtocol:               pulumi.String("sigv4"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	content, err := newCachePolicy(ctx, resource+"-cdn-content", "immutable OCFL version content", contentTTL, contentTTL)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := newCachePolicy(ctx, resource+"-cdn-metadata", "OCFL inventories and other mutable files", 0, metadataTTL)
+	if err != nil {
+		return nil, err
+	}
+	const originID = "ocfl"
+	methods := pulumi.ToStringArray([]string{"GET", "HEAD"})
+	dist, err := cloudfront.NewDistribution(ctx, resource+"-cdn", &cloudfront.DistributionArgs{
+		Enabled:       pulumi.Bool(true),
+		Comment:       pulumi.String(domain),
+		Aliases:       pulumi.StringArray{pulumi.String(domain)},
+		HttpVersion:   pulumi.String("http2and3"),
+		IsIpv6Enabled: pulumi.Bool(true),
+		PriceClass:    pulumi.String(cfg.priceClass()),
+		Origins: cloudfront.DistributionOriginArray{
+			&cloudfront.DistributionOriginArgs{
+				OriginId:              pulumi.String(originID),
+				DomainName:            public.BucketRegionalDomainName,
+				OriginPath:            pulumi.String("/" + strings.TrimSuffix(ocflConfig.Buckets.publicPrefix(), "/")),
+				OriginAccessControlId: oac.ID(),
+			},
+		},
+		DefaultCacheBehavior: &cloudfront.DistributionDefaultCacheBehaviorArgs{
+			TargetOriginId:       pulumi.String(originID),
+			ViewerProtocolPolicy: pulumi.String("redirect-to-https"),
+			AllowedMethods:       methods,
+			CachedMethods:        methods,
+			CachePolicyId:        metadata.ID(),
+			Compress:             pulumi.Bool(true),
+		},
+		OrderedCacheBehaviors: cloudfront.DistributionOrderedCacheBehaviorArray{
+			&cloudfront.DistributionOrderedCacheBehaviorArgs{
+				PathPattern:          pulumi.String(contentPathPattern),
+				TargetOriginId:       pulumi.String(originID),
+				ViewerProtocolPolicy: pulumi.String("redirect-to-https"),
+				AllowedMethods:       methods,
+				CachedMethods:        methods,
+				CachePolicyId:        content.ID(),
+				Compress:             pulumi.Bool(true),
+			},
+		},
+		Restrictions: &cloudfront.DistributionRestrictionsArgs{
+			GeoRestriction: &cloudfront.DistributionRestrictionsGeoRestrictionArgs{
+				RestrictionType: pulumi.String("none"),
+			},
+		},
+		ViewerCertificate: &cloudfront.DistributionViewerCertificateArgs{
+			AcmCertificateArn:      certValidation.CertificateArn,
+			SslSupportMethod:       pulumi.String("sni-only"),
+			MinimumProtocolVersion: pulumi.String("TLSv1.2_2021"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, recordType := range []string{"A", "AAAA"} {
+		_, err = route53.NewRecord(ctx, resource+"-dns-cdn-"+strings.ToLower(recordType), &route53.RecordArgs{
+			Name:   pulumi.String(domain),
+			ZoneId: ocflConfig.DNS.ZoneId,
+			Type:   pulumi.String(recordType),
+			Aliases: route53.RecordAliasArray{
+				&route53.RecordAliasArgs{
+					Name:                 dist.DomainName,
+					ZoneId:               dist.HostedZoneId,
+					EvaluateTargetHealth: pulumi.Bool(false),
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	ctx.Export(cfg.hostname()+"-distributionID", dist.ID())
+	return []*iam.Statement{
+		iam.Allow("s3:GetObject").
+			WithSid("CloudFrontRead").
+			To("Service", pulumi.String("cloudfront.amazonaws.com")).
+			On(pulumi.Sprintf("%s/%s*", public.Arn, ocflConfig.Buckets.publicPrefix())).
+			When(iam.NewCondition("StringEquals", "AWS:SourceArn", dist.Arn)),
+	}, nil
+}
+
+// newCachePolicy creates a cache policy that caches by path only, between
+// minTTL and ttl seconds (the default).
+func newCachePolicy(ctx *pulumi.Context, resource, comment string, minTTL, ttl int) (*cloudfront.CachePolicy, error) {
+	return cloudfront.NewCachePolicy(ctx, resource, &cloudfront.CachePolicyArgs{
+		Name:       pulumi.String(resource),
+		Comment:    pulumi.String(comment),
+		MinTtl:     pulumi.Int(minTTL),
+		DefaultTtl: pulumi.Int(ttl),
+		MaxTtl:     pulumi.Int(ttl),
+		ParametersInCacheKeyAndForwardedToOrigin: &cloudfront.CachePolicyParametersInCacheKeyAndForwardedToOriginArgs{
+			CookiesConfig: &cloudfront.CachePolicyParametersInCacheKeyAndForwardedToOriginCookiesConfigArgs{
+				CookieBehavior: pulumi.String("none"),
+			},
+			HeadersConfig: &cloudfront.CachePolicyParametersInCacheKeyAndForwardedToOriginHeadersConfigArgs{
+				HeaderBehavior: pulumi.String("none"),
+			},
+			QueryStringsConfig: &cloudfront.CachePolicyParametersInCacheKeyAndForwardedToOriginQueryStringsConfigArgs{
+				QueryStringBehavior: pulumi.String("none"),
+			},
+			EnableAcceptEncodingGzip:   pulumi.Bool(true),
+			EnableAcceptEncodingBrotli: pulumi.Bool(true),
+		},
+	})
+}
@@ -0,0 +1,160 @@
+package ocfl
+
+import (
+	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/pulumitest"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	cdnDomainName   = "d111111abcdef8.cloudfront.net"
+	cdnHostedZoneID = "Z2FDTNDATAQYW2"
+	cdnZoneID       = "Z0LAB"
+)
+
+// runCDN runs newBuckets with the CDN enabled, under mocks that give the
+// lab's zone an id, the certificate a validation record and the
+// distribution a domain.
+func runCDN(t *testing.T) *pulumitest.Mocks {
+	t.Helper()
+	mocks := &pulumitest.Mocks{State: map[string]resource.PropertyMap{
+		"aws:route53/zone:Zone": {"zoneId": resource.NewStringProperty(cdnZoneID)},
+		"aws:acm/certificate:Certificate": {
+			"domainValidationOptions": resource.NewArrayProperty([]resource.PropertyValue{
+				resource.NewObjectProperty(resource.PropertyMap{
+					"resourceRecordName":  resource.NewStringProperty("_a1.files.dreamlab.ucsb.edu."),
+					"resourceRecordType":  resource.NewStringProperty("CNAME"),
+					"resourceRecordValue": resource.NewStringProperty("_b2.acm-validations.aws."),
+				}),
+			}),
+		},
+		"aws:cloudfront/distribution:Distribution": {
+			"domainName":   resource.NewStringProperty(cdnDomainName),
+			"hostedZoneId": resource.NewStringProperty(cdnHostedZoneID),
+		},
+	}}
+	err := pulumitest.Run(mocks, map[string]string{"aws:region": "us-west-2"}, func(ctx *pulumi.Context) error {
+		dns, err := dreamlab.NewDNSZone(ctx)
+		if err != nil {
+			return err
+		}
+		return newBuckets(ctx, "data", &Config{
+			DNS:     dns,
+			Buckets: &BucketsConfig{},
+			CDN:     &CDNConfig{Enabled: true},
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mocks
+}
+
+func TestNewCDN(t *testing.T) {
+	mocks := runCDN(t)
+
+	// CloudFront reads the storage root with origin access control
+	var policy struct {
+		Statement []struct {
+			Sid       string
+			Principal map[string][]string
+			Action    []string
+			Resource  []string
+			Condition map[string]map[string][]string
+		}
+	}
+	if err := json.Unmarshal([]byte(inputs(t, mocks, "data-bucket-public-policy")["policy"].(string)), &policy); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, s := range policy.Statement {
+		if s.Sid != "CloudFrontRead" {
+			continue
+		}
+		found = true
+		if got, want := s.Principal["Service"], []string{"cloudfront.amazonaws.com"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("CloudFrontRead principal is %v, want %v", got, want)
+		}
+		if got, want := s.Resource, []string{"arn:mock:data-bucket-public/ocfl/*"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("CloudFrontRead resources are %v, want %v", got, want)
+		}
+		if got, want := s.Condition["StringEquals"]["AWS:SourceArn"], []string{"arn:mock:data-cdn"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("CloudFrontRead source is %v, want %v", got, want)
+		}
+	}
+	if !found {
+		t.Error("no CloudFrontRead statement")
+	}
+
+	dist := inputs(t, mocks, "data-cdn")
+	origin := dist["origins"].([]any)[0].(map[string]any)
+	if origin["originPath"] != "/ocfl" || origin["originAccessControlId"] != "data-cdn-oac_id" {
+		t.Errorf("origin is %v", origin)
+	}
+	if got := dist["aliases"]; !reflect.DeepEqual(got, []any{"files.dreamlab.ucsb.edu"}) {
+		t.Errorf("aliases are %v", got)
+	}
+	if got := dist["defaultCacheBehavior"].(map[string]any)["cachePolicyId"]; got != "data-cdn-metadata_id" {
+		t.Errorf("default cache policy is %v, want the metadata policy", got)
+	}
+	behaviors := dist["orderedCacheBehaviors"].([]any)
+	if len(behaviors) != 1 {
+		t.Fatalf("%d ordered cache behaviors, want 1", len(behaviors))
+	}
+	content := behaviors[0].(map[string]any)
+	if content["pathPattern"] != "*/content/*" || content["cachePolicyId"] != "data-cdn-content_id" {
+		t.Errorf("content cache behavior is %v", content)
+	}
+	ttls := map[string][3]float64{
+		"data-cdn-content":  {contentTTL, contentTTL, contentTTL},
+		"data-cdn-metadata": {0, metadataTTL, metadataTTL},
+	}
+	for name, want := range ttls {
+		p := inputs(t, mocks, name)
+		if got := [3]float64{p["minTtl"].(float64), p["defaultTtl"].(float64), p["maxTtl"].(float64)}; got != want {
+			t.Errorf("%s ttls are %v, want %v", name, got, want)
+		}
+	}
+
+	// cloudfront certificates must be in us-east-1
+	if region := inputs(t, mocks, "data-cdn-provider")["region"]; region != cdnCertificateRegion {
+		t.Errorf("certificate provider region is %v", region)
+	}
+	for _, name := range []string{"data-cdn-cert", "data-cdn-cert-validation"} {
+		if r := mocks.Find(name); r == nil || !strings.Contains(r.Provider, "::data-cdn-provider::") {
+			t.Errorf("%s doesn't use the us-east-1 provider", name)
+		}
+	}
+	if cert := inputs(t, mocks, "data-cdn-cert"); cert["domainName"] != "files.dreamlab.ucsb.edu" || cert["validationMethod"] != "DNS" {
+		t.Errorf("certificate is %v", cert)
+	}
+
+	validation := inputs(t, mocks, "data-dns-cdn-validation")
+	if validation["name"] != "_a1.files.dreamlab.ucsb.edu." || validation["type"] != "CNAME" || validation["zoneId"] != cdnZoneID {
+		t.Errorf("validation record is %v", validation)
+	}
+
+	for _, recordType := range []string{"A", "AAAA"} {
+		name := "data-dns-cdn-" + strings.ToLower(recordType)
+		record := inputs(t, mocks, name)
+		want := map[string]any{
+			"name":   "files.dreamlab.ucsb.edu",
+			"type":   recordType,
+			"zoneId": cdnZoneID,
+			"aliases": []any{map[string]any{
+				"name":                 cdnDomainName,
+				"zoneId":               cdnHostedZoneID,
+				"evaluateTargetHealth": false,
+			}},
+		}
+		if !reflect.DeepEqual(record, want) {
+			t.Errorf("%s is %v, want %v", name, record, want)
+		}
+	}
+}
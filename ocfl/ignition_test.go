@@ -0,0 +1,90 @@
+package ocfl
+
+import (
+	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/pulumitest"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// container.env has the ocfl server's settings only: access is enforced by
+// traefik's routers, not by the server. Filesystems are checked by
+// dreamlab.CheckIgnitionStorage.
+func TestIgnitionContainerEnv(t *testing.T) {
+	stackConfig := map[string]string{
+		"googleOAuth2ClientID":     "client-id",
+		"googleOAuth2ClientSecret": "client-secret",
+		"DataAppSecret":            strings.Repeat("s", 32),
+	}
+	cfg := testHostConfig()
+	// the default stack doesn't auto-update, so the host runs pinned images
+	cfg.AutoUpdate = nil
+	var ign string
+	err := pulumitest.Run(&pulumitest.Mocks{}, stackConfig, func(ctx *pulumi.Context) error {
+		dns, err := dreamlab.NewDNSZone(ctx)
+		if err != nil {
+			return err
+		}
+		cfg.DNS = dns
+		vols, err := dreamlab.NewHostVolumes(ctx, "data", &dreamlab.HostVolumesArgs{
+			Hostname:         cfg.Hostname,
+			AvailabilityZone: pulumi.String("us-west-2a"),
+		})
+		if err != nil {
+			return err
+		}
+		out, err := ignition(ctx, cfg, vols, "aarch64")
+		if err != nil {
+			return err
+		}
+		out.ApplyT(func(s string) string {
+			ign = s
+			return s
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := dreamlab.IgnitionFiles(ign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, contents := range files {
+		if !strings.HasSuffix(path, ".container") {
+			continue
+		}
+		for _, line := range strings.Split(contents, "\n") {
+			if strings.HasPrefix(line, "Image=") && !strings.Contains(line, "@sha256:") {
+				t.Errorf("%s: %s isn't pinned by digest", path, line)
+			}
+		}
+	}
+	got, ok := files["/etc/ocfl-server/container.env"]
+	if !ok {
+		t.Fatal("no /etc/ocfl-server/container.env")
+	}
+	want := "AWS_REGION=us-west-2\nOCFL_ROOT=s3://dreamlab-public/ocfl\n"
+	if got != want {
+		t.Errorf("container.env = %q, want %q", got, want)
+	}
+}
+
+// The default stack config doesn't auto-update containers, so every image
+// the host runs must be pinned in the manifest (dreamlab images check -pin).
+func TestImageNamesPinned(t *testing.T) {
+	for _, arch := range []string{"aarch64"} {
+		refs, err := dreamlab.ImageRefs(arch, nil, imageNames("s3://bucket/acme")...)
+		if err != nil {
+			t.Errorf("%s: %v", arch, err)
+			continue
+		}
+		for name, ref := range refs {
+			if !strings.Contains(ref, "@sha256:") {
+				t.Errorf("%s: %s isn't pinned: %s", arch, name, ref)
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package ocfl
 
 import (
 	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/quadlet"
 	_ "embed"
 	"fmt"
 	"path/filepath"
@@ -11,7 +12,6 @@ import (
 	butaneConfig "github.com/coreos/butane/config"
 	"github.com/coreos/butane/config/common"
 	"github.com/hashicorp/go-multierror"
-	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ebs"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/route53"
@@ -28,9 +28,6 @@ const policyEC2AssumeRole = `{
 	}]
 }`
 
-//go:embed policy.json
-var awsPolicy string
-
 //go:embed butane.yml
 var butaneYML string
 
@@ -38,16 +35,34 @@ type Config struct {
 	VPC          *dreamlab.AWSVPC
 	DNS          *dreamlab.DNS
 	Hostname     string
-	InstanceAMI  string // should be fedora coreos
-	InstanceType string // shoube be arm64
+	InstanceAMI  string // should be fedora coreos; if empty, FCOSStream's current AMI is used
+	FCOSStream   string // default: stable
+	Updates      *dreamlab.UpdatesConfig
+	InstanceType string // must match the AMI's architecture
+	Backups      *dreamlab.BackupConfig
+	AutoUpdate   *dreamlab.AutoUpdateConfig // containers podman auto-updates by tag
+	Traefik      *dreamlab.TraefikConfig
+	Buckets      *BucketsConfig // OCFL content buckets
+	CDN          *CDNConfig     // CloudFront distribution of the storage root
+	Access       *AccessConfig  // users who can sign in, and who can write
+	// persistent volume for container volumes and additional volumes
+	VarVolume dreamlab.VolumeConfig
+	Volumes   []dreamlab.VolumeConfig
+	// encrypt volumes with this KMS key instead of the AWS managed key
+	KMSKeyArn pulumi.StringInput
+	// create the persistent volume from this snapshot (snap-...)
+	RestoreFromSnapshot string
 }
 
 func New(ctx *pulumi.Context, resource string, ocflConfig *Config) error {
+	if err := ocflConfig.Access.validate(); err != nil {
+		return fmt.Errorf("%s: %w", resource, err)
+	}
 	sgResource := resource + "-sg"
 	sg, err := ec2.NewSecurityGroup(ctx, sgResource, &ec2.SecurityGroupArgs{
 		Name:  pulumi.String(sgResource),
 		VpcId: ocflConfig.VPC.Vpc.ID(),
-		Ingress: &ec2.SecurityGroupIngressArray{
+		Ingress: append(ec2.SecurityGroupIngressArray{
 			&ec2.SecurityGroupIngressArgs{
 				FromPort:       pulumi.Int(22),
 				ToPort:         pulumi.Int(22),
@@ -69,7 +84,7 @@ func New(ctx *pulumi.Context, resource string, ocflConfig *Config) error {
 				CidrBlocks:     pulumi.StringArray{pulumi.String("0.0.0.0/0")},
 				Ipv6CidrBlocks: pulumi.StringArray{pulumi.String("::/0")},
 			},
-		},
+		}, ocflConfig.Traefik.Ingress(ocflConfig.VPC)...),
 		Egress: &ec2.SecurityGroupEgressArray{
 			&ec2.SecurityGroupEgressArgs{
 				FromPort:       pulumi.Int(0),
@@ -94,6 +109,9 @@ func New(ctx *pulumi.Context, resource string, ocflConfig *Config) error {
 	if err != nil {
 		return err
 	}
+	if err := newBuckets(ctx, resource, ocflConfig); err != nil {
+		return err
+	}
 	// create an instance profile for the vm
 	roleResource := resource + "-role"
 	role, err := iam.NewRole(ctx, roleResource, &iam.RoleArgs{
@@ -106,7 +124,7 @@ func New(ctx *pulumi.Context, resource string, ocflConfig *Config) error {
 	policyResource := roleResource + "-policy"
 	_, err = iam.NewRolePolicy(ctx, policyResource, &iam.RolePolicyArgs{
 		Role:   role.Name,
-		Policy: pulumi.String(awsPolicy),
+		Policy: policy(ocflConfig),
 	})
 	if err != nil {
 		return err
@@ -119,16 +137,29 @@ func New(ctx *pulumi.Context, resource string, ocflConfig *Config) error {
 		return err
 	}
 	// persistent storage
-	varVolRescource := resource + "-var"
-	varVol, err := ebs.NewVolume(ctx, varVolRescource, &ebs.VolumeArgs{
-		AvailabilityZone: ocflConfig.VPC.Public.AvailabilityZone,
-		Size:             pulumi.IntPtr(64),
-		Type:             pulumi.StringPtr("gp3"),
+	vols, err := dreamlab.NewHostVolumes(ctx, resource, &dreamlab.HostVolumesArgs{
+		Hostname:            ocflConfig.Hostname,
+		AvailabilityZone:    ocflConfig.VPC.Public.AvailabilityZone,
+		Var:                 ocflConfig.VarVolume,
+		Extra:               ocflConfig.Volumes,
+		KMSKeyArn:           ocflConfig.KMSKeyArn,
+		RestoreFromSnapshot: ocflConfig.RestoreFromSnapshot,
 	})
 	if err != nil {
 		return err
 	}
-	userData, err := ignition(ctx, ocflConfig)
+	if err := dreamlab.NewBackupPolicy(ctx, resource, ocflConfig.Hostname, ocflConfig.Backups); err != nil {
+		return err
+	}
+	arch, err := dreamlab.InstanceArch(ocflConfig.InstanceType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", resource, err)
+	}
+	ami, instanceOpts, err := dreamlab.InstanceAMI(ctx, ocflConfig.InstanceAMI, ocflConfig.FCOSStream, arch)
+	if err != nil {
+		return err
+	}
+	userData, err := ignition(ctx, ocflConfig, vols, arch)
 	if err != nil {
 		return err
 	}
@@ -136,7 +167,7 @@ func New(ctx *pulumi.Context, resource string, ocflConfig *Config) error {
 	instanceArgs := &ec2.InstanceArgs{
 		IamInstanceProfile:  profile.Name,
 		SubnetId:            ocflConfig.VPC.Public.ID(),
-		Ami:                 pulumi.String(ocflConfig.InstanceAMI),
+		Ami:                 pulumi.String(ami),
 		InstanceType:        pulumi.String(ocflConfig.InstanceType),
 		KeyName:             kp.KeyName,
 		VpcSecurityGroupIds: pulumi.StringArray{sg.ID()},
@@ -147,18 +178,13 @@ func New(ctx *pulumi.Context, resource string, ocflConfig *Config) error {
 		UserData:                userData,
 		UserDataReplaceOnChange: pulumi.Bool(true),
 	}
-	inst, err := ec2.NewInstance(ctx, instResource, instanceArgs, pulumi.DeleteBeforeReplace(true))
+	instanceOpts = append(instanceOpts, pulumi.DeleteBeforeReplace(true))
+	inst, err := ec2.NewInstance(ctx, instResource, instanceArgs, instanceOpts...)
 	if err != nil {
 		return err
 	}
-	// Attach the volume to the existing EC2 instance.
-	_, err = ec2.NewVolumeAttachment(ctx, varVolRescource+"-attach", &ec2.VolumeAttachmentArgs{
-		InstanceId:                  inst.ID(),
-		VolumeId:                    varVol.ID(),
-		DeviceName:                  pulumi.String("/dev/sdf"),
-		StopInstanceBeforeDetaching: pulumi.BoolPtr(true),
-	}, pulumi.DeleteBeforeReplace(true))
-	if err != nil {
+	// Attach the volumes to the existing EC2 instance.
+	if err := dreamlab.AttachHostVolumes(ctx, resource, inst.ID(), vols); err != nil {
 		return err
 	}
 	eiPResource := resource + "-eip"
@@ -181,52 +207,116 @@ func New(ctx *pulumi.Context, resource string, ocflConfig *Config) error {
 		return err
 	}
 
-	authRecordResource := resource + "dns-auth"
-	_, err = route53.NewRecord(ctx, authRecordResource, &route53.RecordArgs{
-		Name:    pulumi.String("auth." + ocflConfig.DNS.Domain()),
-		ZoneId:  ocflConfig.DNS.ZoneId,
-		Type:    pulumi.String("A"),
-		Records: pulumi.StringArray{eip.PublicIp},
-		Ttl:     pulumi.Int(600),
-	})
-	if err != nil {
-		return err
+	if ocflConfig.Traefik.Dashboard {
+		_, err = route53.NewRecord(ctx, resource+"-dns-traefik", &route53.RecordArgs{
+			Name:    pulumi.String("traefik." + ocflConfig.Hostname + "." + ocflConfig.DNS.Domain()),
+			ZoneId:  ocflConfig.DNS.ZoneId,
+			Type:    pulumi.String("A"),
+			Records: pulumi.StringArray{eip.PublicIp},
+			Ttl:     pulumi.Int(600),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	domain := ocflConfig.DNS.Domain()
+	// tinyauth's login pages, for readers (and other hosts) and for writers
+	authRecords := []struct {
+		resource, name string
+		opts           []pulumi.ResourceOption
+	}{
+		// the readers' record was named without a hyphen
+		{resource + "-dns-auth", "auth." + domain, []pulumi.ResourceOption{
+			pulumi.Aliases([]pulumi.Alias{{Name: pulumi.String(resource + "dns-auth")}}),
+		}},
+		{resource + "-dns-auth-write", "auth." + ocflConfig.Hostname + "." + domain, nil},
+	}
+	for _, r := range authRecords {
+		_, err = route53.NewRecord(ctx, r.resource, &route53.RecordArgs{
+			Name:    pulumi.String(r.name),
+			ZoneId:  ocflConfig.DNS.ZoneId,
+			Type:    pulumi.String("A"),
+			Records: pulumi.StringArray{eip.PublicIp},
+			Ttl:     pulumi.Int(600),
+		}, r.opts...)
+		if err != nil {
+			return err
+		}
 	}
 	ctx.Export(ocflConfig.Hostname+"-publicIP", eip.PublicIp)
 	return nil
 }
 
+// imageNames returns the manifest images the host runs. aws-cli copies
+// traefik's certificates to and from acmeStorage, if it is set.
+func imageNames(acmeStorage string) []string {
+	names := []string{"traefik", "tinyauth", "ocfl-server"}
+	if acmeStorage != "" {
+		names = append(names, "aws-cli")
+	}
+	return names
+}
+
 // build fedora coreos ignition user data for the machine.
-func ignition(ctx *pulumi.Context, ocflConfig *Config) (pulumi.StringOutput, error) {
+func ignition(ctx *pulumi.Context, ocflConfig *Config, vols []*dreamlab.HostVolume, arch string) (pulumi.StringOutput, error) {
 	var out pulumi.StringOutput
+	zincati, err := ocflConfig.Updates.ZincatiTOML()
+	if err != nil {
+		return out, fmt.Errorf("%s updates: %w", ocflConfig.Hostname, err)
+	}
+	traefikYML, err := ocflConfig.Traefik.StaticConfig()
+	if err != nil {
+		return out, fmt.Errorf("%s: %w", ocflConfig.Hostname, err)
+	}
+	traefikDynamicYML, err := ocflConfig.Traefik.DynamicConfig(ocflConfig.DNS.Domain())
+	if err != nil {
+		return out, fmt.Errorf("%s: %w", ocflConfig.Hostname, err)
+	}
+	acmeStorage := ocflConfig.Traefik.ACME.StorageURL(ocflConfig.Hostname)
+	images, err := dreamlab.ImageRefs(arch, ocflConfig.AutoUpdate, imageNames(acmeStorage)...)
+	if err != nil {
+		return out, err
+	}
 	cfg := config.New(ctx, "")
 	out = pulumi.All(
 		cfg.GetSecret("googleOAuth2ClientID"),
 		cfg.GetSecret("googleOAuth2ClientSecret"),
-		cfg.GetSecret("DataAdminPassword"),
 		cfg.GetSecret("DataAppSecret"),
+		dreamlab.VolumeIDs(vols),
 	).ApplyT(func(args []any) (string, error) {
+		units := quadlets(ocflConfig, images, args[2].(string), args[0].(string), args[1].(string))
+		if err := quadlet.LintTraefik(units, ocflConfig.Traefik.FileMiddlewares()); err != nil {
+			return "", fmt.Errorf("%s traefik labels: %w", ocflConfig.Hostname, err)
+		}
 		vals := struct {
-			OIDCClientID      string
-			OIDCClientSecret  string
-			DataAdminPassword string
-			DataAppSecret     string
+			Mounts            []dreamlab.Mount
+			Quadlets          []quadlet.Unit
+			ZincatiTOML       string
+			TraefikYML        string
+			TraefikDynamicYML string
+			TraefikAccessLog  bool
+			ACMEStorage       string
+			OCFLRoot          string
+			AWSCLIImage       string
+			AutoUpdate        *dreamlab.AutoUpdateConfig
 			Hostname          string
 			Domain            string
 		}{
-			OIDCClientID:      args[0].(string),
-			OIDCClientSecret:  args[1].(string),
-			DataAdminPassword: args[2].(string),
-			DataAppSecret:     args[3].(string),
+			Mounts:            dreamlab.Mounts(vols, args[3].([]string)),
+			Quadlets:          units,
+			ZincatiTOML:       zincati,
+			TraefikYML:        traefikYML,
+			TraefikDynamicYML: traefikDynamicYML,
+			TraefikAccessLog:  ocflConfig.Traefik.AccessLog,
+			ACMEStorage:       acmeStorage,
+			OCFLRoot:          ocflConfig.Buckets.StorageRoot(),
+			AWSCLIImage:       images["aws-cli"],
+			AutoUpdate:        ocflConfig.AutoUpdate,
 			Hostname:          ocflConfig.Hostname,
 			Domain:            ocflConfig.DNS.Domain(),
 		}
-		myFuncs := template.FuncMap{
-			"domainEscape": func(d string) string {
-				return strings.ReplaceAll(d, `.`, `\\.`)
-			},
-		}
-		tpl, err := template.New("butane").Funcs(myFuncs).Parse(string(butaneYML))
+		tpl, err := template.New("butane").Funcs(dreamlab.ButaneFuncs).Parse(string(butaneYML))
 		if err != nil {
 			return "", err
 		}
@@ -250,6 +340,9 @@ func ignition(ctx *pulumi.Context, ocflConfig *Config) (pulumi.StringOutput, err
 			}
 			return "", err
 		}
+		if err := dreamlab.CheckIgnitionStorage(string(ign)); err != nil {
+			return "", err
+		}
 		return string(ign), nil
 	}).(pulumi.StringOutput)
 	return out, nil
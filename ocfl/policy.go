@@ -0,0 +1,32 @@
+package ocfl
+
+import (
+	"dreamlab/internal/dreamlab/iam"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// instance role policy for the ocfl server
+func policy(ocflConfig *Config) pulumi.StringOutput {
+	buckets := ocflConfig.Buckets
+	stmts := ocflConfig.DNS.ACMEStatements()
+	stmts = append(stmts, ocflConfig.Traefik.Statements(ocflConfig.Hostname)...)
+	stmts = append(stmts,
+		iam.Allow(
+			"s3:ListBucket",
+			"s3:DeleteObject",
+			"s3:GetObject",
+			"s3:PutObject",
+			"s3:PutObjectAcl",
+		).WithSid("OCFLBuckets").On(
+			pulumi.String("arn:aws:s3:::"+buckets.publicBucket()+"/*"),
+			pulumi.String("arn:aws:s3:::"+buckets.publicBucket()),
+			pulumi.String("arn:aws:s3:::"+buckets.restrictedBucket()+"/*"),
+			pulumi.String("arn:aws:s3:::"+buckets.restrictedBucket()),
+		),
+		iam.Allow("s3:ListAllMyBuckets").
+			WithSid("OCFLListBuckets").
+			On(pulumi.String("arn:aws:s3:::*")),
+	)
+	return iam.Document(stmts...)
+}
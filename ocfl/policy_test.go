@@ -0,0 +1,52 @@
+package ocfl
+
+import (
+	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/pulumitest"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// the ACME statements are tested in package dreamlab; the host must not be
+// granted every hosted zone
+func TestPolicyHostedZones(t *testing.T) {
+	var policyJSON string
+	err := pulumitest.Run(&pulumitest.Mocks{}, nil, func(ctx *pulumi.Context) error {
+		dns, err := dreamlab.NewDNSZone(ctx)
+		if err != nil {
+			return err
+		}
+		cfg := &Config{
+			DNS:      dns,
+			Hostname: "data",
+			Traefik:  &dreamlab.TraefikConfig{ACME: &dreamlab.ACMEConfig{Email: "admin@ucsb.edu", Storage: "s3://bucket/acme"}},
+		}
+		policy(cfg).ApplyT(func(s string) string {
+			policyJSON = s
+			return s
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var policy struct {
+		Statement []struct {
+			Sid      string
+			Resource []string
+		}
+	}
+	if err := json.Unmarshal([]byte(policyJSON), &policy); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range policy.Statement {
+		for _, r := range s.Resource {
+			if strings.Contains(r, "hostedzone/*") {
+				t.Errorf("%s: grants every hosted zone (%s)", s.Sid, r)
+			}
+		}
+	}
+}
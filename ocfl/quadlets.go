@@ -0,0 +1,162 @@
+package ocfl
+
+import (
+	"crypto/sha256"
+	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/quadlet"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	// ocflStartCheck waits up to 80s (within systemd's default start
+	// timeout) for the ocfl server to answer on its published port.
+	ocflStartCheck = "/usr/bin/curl -fsS -o /dev/null --max-time 5 --retry 8 --retry-delay 10 --retry-all-errors http://localhost:8080/"
+	// ocflWritePriority puts the write router ahead of ocfl-secure, whose
+	// priority is its rule's length.
+	ocflWritePriority = 100
+)
+
+// readMethods are the requests readers can make; other methods change
+// content and need a writer.
+var readMethods = []string{"GET", "HEAD", "OPTIONS"}
+
+// writeRule matches requests to host that aren't readMethods.
+func writeRule(host string) string {
+	methods := make([]string, len(readMethods))
+	for i, m := range readMethods {
+		methods[i] = "Method(`" + m + "`)"
+	}
+	return "Host(`" + host + "`) && !(" + strings.Join(methods, " || ") + ")"
+}
+
+// writeSecret derives the writers' tinyauth secret from the readers', so
+// a session from one instance isn't valid for the other. tinyauth needs a
+// 32 character secret.
+func writeSecret(authSecret string) string {
+	sum := sha256.Sum256([]byte("write:" + authSecret))
+	return hex.EncodeToString(sum[:])[:32]
+}
+
+// newTinyauth returns a tinyauth container that serves its login page on
+// authHost and defines a forward-auth middleware with the container's name.
+// Users sign in with Google (the OAuth client coder uses), and only the
+// emails are allowed. The signed-in user's email is passed to services in
+// the Remote-Email header.
+func newTinyauth(name, image, authHost, secret, clientID, clientSecret string, emails []string) *quadlet.Container {
+	authRouter := quadlet.Router{
+		Name:         name,
+		EntryPoints:  []string{dreamlab.TraefikEntryPoint},
+		Rule:         "Host(`" + authHost + "`)",
+		CertResolver: dreamlab.TraefikCertResolver,
+		Domains:      []string{authHost},
+		Middlewares:  []string{dreamlab.ProfilePublic},
+		Public:       true,
+	}
+	return &quadlet.Container{
+		Name:          name,
+		Description:   "Tinyauth Proxy",
+		ContainerName: name,
+		Image:         image,
+		// the image is alpine based: wget is busybox's
+		Health: &quadlet.HealthCheck{
+			Cmd:         "wget -q -O /dev/null http://localhost:3000/api/healthcheck",
+			Interval:    "30s",
+			Retries:     3,
+			StartPeriod: "2m",
+		},
+		Environment: []string{
+			"SECRET=" + secret,
+			"APP_URL=https://" + authHost,
+			"GOOGLE_CLIENT_ID=" + clientID,
+			"GOOGLE_CLIENT_SECRET=" + clientSecret,
+			"OAUTH_WHITELIST=" + strings.Join(emails, ","),
+			"OAUTH_AUTO_REDIRECT=google",
+		},
+		Labels: append(append([]quadlet.Label{quadlet.TraefikEnable}, authRouter.Labels()...),
+			quadlet.Middleware(name, "forwardauth.address", "http://"+name+":3000/api/auth/traefik"),
+			quadlet.Middleware(name, "forwardauth.authResponseHeaders", accessHeader),
+		),
+	}
+}
+
+// quadlets returns the host's container, volume and network units. The
+// tinyauth secret and the Google OAuth client are resolved secrets from the
+// stack config.
+func quadlets(ocflConfig *Config, images map[string]string, authSecret, clientID, clientSecret string) []quadlet.Unit {
+	domain := ocflConfig.DNS.Domain()
+	host := ocflConfig.Hostname + "." + domain
+	network := &quadlet.Network{Name: "ocfl", NetworkName: "ocfl"}
+
+	traefik := ocflConfig.Traefik.Container(images["traefik"], ocflConfig.Hostname, domain)
+	traefik.Networks = []string{network.FileName()}
+	traefik.PublishPorts = []string{"443:443"}
+	if ocflConfig.Traefik.Metrics {
+		port := dreamlab.TraefikMetricsPort
+		traefik.PublishPorts = append(traefik.PublishPorts, fmt.Sprintf("%d:%d", port, port))
+	}
+	ocflConfig.AutoUpdate.Configure("traefik", traefik)
+
+	// tinyauth on auth.<domain> provides the forward-auth middleware for
+	// readers, and for other hosts' routers. Routers on this host use it
+	// directly instead of the authenticated profile, so forward-auth
+	// requests aren't rate limited as coming from the host's own address.
+	// Like that profile, they list it after ProfilePublic, so requests are
+	// rate limited before they reach tinyauth.
+	tinyauth := newTinyauth("tinyauth", images["tinyauth"], "auth."+domain,
+		authSecret, clientID, clientSecret, ocflConfig.Access.readers())
+	tinyauth.Networks = []string{network.FileName()}
+	tinyauth.PublishPorts = []string{"3000:3000"}
+	ocflConfig.AutoUpdate.Configure("tinyauth", tinyauth)
+
+	// tinyauth only checks its whitelist when users sign in, so writers
+	// sign in again to a second instance on auth.<host>. Its session
+	// cookie is scoped to the data host, apart from the lab-wide one.
+	tinyauthWrite := newTinyauth("tinyauth-write", images["tinyauth"], "auth."+host,
+		writeSecret(authSecret), clientID, clientSecret, ocflConfig.Access.writers())
+	tinyauthWrite.Networks = []string{network.FileName()}
+	ocflConfig.AutoUpdate.Configure("tinyauth", tinyauthWrite)
+
+	router := quadlet.Router{
+		Name:         "ocfl-secure",
+		EntryPoints:  []string{dreamlab.TraefikEntryPoint},
+		Rule:         "Host(`" + host + "`)",
+		CertResolver: dreamlab.TraefikCertResolver,
+		Domains:      []string{host},
+		Middlewares:  []string{dreamlab.ProfilePublic, "tinyauth", dreamlab.BaselineCSP},
+	}
+	writeRouter := router
+	writeRouter.Name = "ocfl-write"
+	writeRouter.Rule = writeRule(host)
+	writeRouter.Priority = ocflWritePriority
+	writeRouter.Middlewares = []string{dreamlab.ProfilePublic, "tinyauth-write", dreamlab.BaselineCSP}
+	ocfl := &quadlet.Container{
+		Name:          "ocfl",
+		Description:   "OCFL Server",
+		ContainerName: "ocfl-server",
+		Image:         images["ocfl-server"],
+		// the ko-built image has no shell or wget for a health check, so
+		// the host probes the server before the unit is started. If an
+		// auto-updated image doesn't serve, the unit fails and podman
+		// rolls it back.
+		ExecStartPost:    []string{ocflStartCheck},
+		Exec:             "-uploads /data/uploads -index /data/ocfl-server.db",
+		Networks:         []string{network.FileName()},
+		PublishPorts:     []string{"8080:8080"},
+		Volumes:          []string{"ocfl-data.volume:/data"},
+		EnvironmentFiles: []string{"/etc/ocfl-server/container.env"},
+		Labels:           append(append([]quadlet.Label{quadlet.TraefikEnable}, router.Labels()...), writeRouter.Labels()...),
+	}
+	ocflConfig.AutoUpdate.Configure("ocfl-server", ocfl)
+
+	units := []quadlet.Unit{
+		network,
+		traefik,
+		tinyauth,
+		tinyauthWrite,
+		ocfl,
+		&quadlet.Volume{Name: "ocfl-data", VolumeName: "ocfl-data"},
+	}
+	return append(units, ocflConfig.Traefik.Volumes()...)
+}
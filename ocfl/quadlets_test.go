@@ -0,0 +1,162 @@
+package ocfl
+
+import (
+	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/pulumitest"
+	"dreamlab/internal/dreamlab/quadlet"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// testHostConfig is a data host whose containers podman auto-updates
+func testHostConfig() *Config {
+	return &Config{
+		Hostname:   "data",
+		AutoUpdate: &dreamlab.AutoUpdateConfig{Services: []string{"traefik", "tinyauth", "ocfl-server"}},
+		Traefik: &dreamlab.TraefikConfig{
+			ACME: &dreamlab.ACMEConfig{Email: "admin@ucsb.edu"},
+		},
+		Buckets: &BucketsConfig{},
+		Access: &AccessConfig{
+			Groups: map[string][]string{"staff": {"a@ucsb.edu", "b@ucsb.edu"}},
+			Read:   []string{"r@ucsb.edu"},
+			Write:  []string{"staff"},
+		},
+	}
+}
+
+// testQuadlets returns the host's units for the config, with a DNS zone
+// from mocks.
+func testQuadlets(t *testing.T, cfg *Config) []quadlet.Unit {
+	t.Helper()
+	err := pulumitest.Run(&pulumitest.Mocks{}, nil, func(ctx *pulumi.Context) error {
+		dns, err := dreamlab.NewDNSZone(ctx)
+		cfg.DNS = dns
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	images := map[string]string{"traefik": "traefik:v3.4", "tinyauth": "tinyauth:v3", "ocfl-server": "ocfl-server:latest"}
+	return quadlets(cfg, images, "auth-secret", "client-id", "client-secret")
+}
+
+// unitFiles returns the units' contents by file name
+func unitFiles(units []quadlet.Unit) map[string]string {
+	files := map[string]string{}
+	for _, u := range units {
+		files[u.FileName()] = u.String()
+	}
+	return files
+}
+
+// ocfl-server's image has no shell or wget: auto-updates are checked by a
+// probe from the host, not a health check that would never pass.
+func TestQuadletsAutoUpdateChecks(t *testing.T) {
+	cfg := testHostConfig()
+	units := unitFiles(testQuadlets(t, cfg))
+	ocfl := units["ocfl.container"]
+	for _, key := range []string{"HealthCmd=", "Notify=healthy"} {
+		if strings.Contains(ocfl, key) {
+			t.Errorf("ocfl.container sets %s", key)
+		}
+	}
+	if !strings.Contains(ocfl, "[Service]\nExecStartPost="+ocflStartCheck+"\n") {
+		t.Errorf("ocfl.container doesn't probe the server:\n%s", ocfl)
+	}
+	for _, name := range []string{"tinyauth.container", "tinyauth-write.container", "traefik.container"} {
+		if !strings.Contains(units[name], "AutoUpdate=registry") {
+			t.Errorf("%s isn't auto-updated", name)
+		}
+		if strings.Contains(units[name], "Notify=healthy") && !strings.Contains(units[name], "HealthCmd=") {
+			t.Errorf("%s waits to be healthy without a health check", name)
+		}
+	}
+}
+
+func TestQuadletsLintTraefik(t *testing.T) {
+	for _, dashboard := range []bool{false, true} {
+		cfg := testHostConfig()
+		cfg.Traefik.Dashboard = dashboard
+		if err := quadlet.LintTraefik(testQuadlets(t, cfg), cfg.Traefik.FileMiddlewares()); err != nil {
+			t.Errorf("dashboard %v: %v", dashboard, err)
+		}
+	}
+}
+
+// Readers sign in to tinyauth; other methods on the data host need a
+// writer signed in to tinyauth-write.
+func TestQuadletsAccess(t *testing.T) {
+	cfg := testHostConfig()
+	units := unitFiles(testQuadlets(t, cfg))
+	env := []struct {
+		unit string
+		want []string
+	}{
+		{"tinyauth.container", []string{
+			"Environment=SECRET=auth-secret",
+			"Environment=APP_URL=https://auth.dreamlab.ucsb.edu",
+			"Environment=GOOGLE_CLIENT_ID=client-id",
+			"Environment=GOOGLE_CLIENT_SECRET=client-secret",
+			"Environment=OAUTH_WHITELIST=a@ucsb.edu,b@ucsb.edu,r@ucsb.edu",
+			"Environment=OAUTH_AUTO_REDIRECT=google",
+		}},
+		{"tinyauth-write.container", []string{
+			"Environment=SECRET=" + writeSecret("auth-secret"),
+			"Environment=APP_URL=https://auth.data.dreamlab.ucsb.edu",
+			"Environment=GOOGLE_CLIENT_ID=client-id",
+			"Environment=GOOGLE_CLIENT_SECRET=client-secret",
+			"Environment=OAUTH_WHITELIST=a@ucsb.edu,b@ucsb.edu",
+			"Environment=OAUTH_AUTO_REDIRECT=google",
+		}},
+	}
+	for _, tt := range env {
+		lines := strings.Split(units[tt.unit], "\n")
+		for _, want := range tt.want {
+			if !slices.Contains(lines, want) {
+				t.Errorf("%s: no %s:\n%s", tt.unit, want, units[tt.unit])
+			}
+		}
+	}
+	if s := writeSecret("auth-secret"); len(s) != 32 || s == "auth-secret" {
+		t.Errorf("writeSecret = %q, want a different 32 character secret", s)
+	}
+
+	labels := map[string]string{}
+	for _, name := range []string{"tinyauth.container", "tinyauth-write.container", "ocfl.container"} {
+		ls, err := quadlet.ParseLabels(units[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range ls {
+			labels[l.Key] = l.Value
+		}
+	}
+	wantLabels := map[string]string{
+		"traefik.http.routers.tinyauth.rule":                                      "Host(`auth.dreamlab.ucsb.edu`)",
+		"traefik.http.routers.tinyauth-write.rule":                                "Host(`auth.data.dreamlab.ucsb.edu`)",
+		"traefik.http.middlewares.tinyauth.forwardauth.address":                   "http://tinyauth:3000/api/auth/traefik",
+		"traefik.http.middlewares.tinyauth.forwardauth.authResponseHeaders":       "Remote-Email",
+		"traefik.http.middlewares.tinyauth-write.forwardauth.address":             "http://tinyauth-write:3000/api/auth/traefik",
+		"traefik.http.middlewares.tinyauth-write.forwardauth.authResponseHeaders": "Remote-Email",
+		"traefik.http.routers.ocfl-secure.rule":                                   "Host(`data.dreamlab.ucsb.edu`)",
+		"traefik.http.routers.ocfl-secure.middlewares":                            "public@file,tinyauth,baseline-csp@file",
+		"traefik.http.routers.ocfl-write.rule":                                    "Host(`data.dreamlab.ucsb.edu`) && !(Method(`GET`) || Method(`HEAD`) || Method(`OPTIONS`))",
+		"traefik.http.routers.ocfl-write.priority":                                "100",
+		"traefik.http.routers.ocfl-write.middlewares":                             "public@file,tinyauth-write,baseline-csp@file",
+		"traefik.http.routers.ocfl-write.tls.domains[0].main":                     "data.dreamlab.ucsb.edu",
+	}
+	for key, want := range wantLabels {
+		if got := labels[key]; got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	// writes must not fall through to the readers' router
+	secureLen := len(labels["traefik.http.routers.ocfl-secure.rule"])
+	if ocflWritePriority <= secureLen {
+		t.Errorf("ocfl-write priority %d isn't above ocfl-secure's %d", ocflWritePriority, secureLen)
+	}
+}
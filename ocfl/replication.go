@@ -0,0 +1,258 @@
+package ocfl
+
+import (
+	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/iam"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	awsiam "github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/s3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+const policyS3AssumeRole = `{
+	"Version": "2012-10-17",
+	"Statement": [{
+		"Effect": "Allow",
+		"Action": "sts:AssumeRole",
+		"Principal": {"Service": "s3.amazonaws.com"}
+	}]
+}`
+
+const (
+	// objects smaller than this aren't tiered (default: 1 MiB)
+	defaultTieringMinSize = 1 << 20
+	// Intelligent-Tiering doesn't move objects smaller than 128 KiB, and
+	// Glacier Instant Retrieval bills them as 128 KiB
+	minTieringMinSize = 128 << 10
+)
+
+// storage classes that replicas can be written as
+var replicaStorageClasses = []string{
+	"STANDARD",
+	"STANDARD_IA",
+	"INTELLIGENT_TIERING",
+	"GLACIER_IR",
+	"GLACIER",
+	"DEEP_ARCHIVE",
+}
+
+// TieringConfig transitions content in the OCFL storage root to cheaper
+// storage classes. S3 lifecycle rules can't match an object's content
+// directory by path, so every object larger than MinSize is transitioned.
+// Sidecars and declarations are small and stay in Standard, as do most
+// inventories, but the inventory of an object with many files or versions
+// can be larger than MinSize: it is tiered too, and the ocfl server reads
+// it from Glacier Instant Retrieval at a higher cost.
+type TieringConfig struct {
+	// MinSize is the smallest object, in bytes, that is transitioned
+	// (default: 1 MiB, at least 128 KiB).
+	MinSize int `json:"minSize"`
+	// IntelligentTieringDays moves content to Intelligent-Tiering after this
+	// many days (0 doesn't).
+	IntelligentTieringDays int `json:"intelligentTieringDays"`
+	// GlacierDays moves content to Glacier Instant Retrieval after this many
+	// days (0 doesn't). It must be after IntelligentTieringDays.
+	GlacierDays int `json:"glacierDays"`
+}
+
+func (c *TieringConfig) minSize() int {
+	if c.MinSize == 0 {
+		return defaultTieringMinSize
+	}
+	return c.MinSize
+}
+
+func (c *TieringConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	switch {
+	case c.minSize() < minTieringMinSize:
+		return fmt.Errorf("tiering: minSize must be at least %d bytes (128 KiB)", minTieringMinSize)
+	case c.IntelligentTieringDays < 0 || c.GlacierDays < 0:
+		return fmt.Errorf("tiering: days can't be negative")
+	case c.IntelligentTieringDays == 0 && c.GlacierDays == 0:
+		return fmt.Errorf("tiering: set intelligentTieringDays or glacierDays")
+	case c.IntelligentTieringDays > 0 && c.GlacierDays > 0 && c.GlacierDays <= c.IntelligentTieringDays:
+		return fmt.Errorf("tiering: glacierDays must be after intelligentTieringDays")
+	}
+	return nil
+}
+
+// rules returns the lifecycle rule that tiers content under the prefix, or
+// nil if tiering isn't configured.
+func (c *TieringConfig) rules(prefix string) s3.BucketLifecycleConfigurationV2RuleArray {
+	if c == nil {
+		return nil
+	}
+	var transitions s3.BucketLifecycleConfigurationV2RuleTransitionArray
+	if c.IntelligentTieringDays > 0 {
+		transitions = append(transitions, &s3.BucketLifecycleConfigurationV2RuleTransitionArgs{
+			Days:         pulumi.Int(c.IntelligentTieringDays),
+			StorageClass: pulumi.String("INTELLIGENT_TIERING"),
+		})
+	}
+	if c.GlacierDays > 0 {
+		transitions = append(transitions, &s3.BucketLifecycleConfigurationV2RuleTransitionArgs{
+			Days:         pulumi.Int(c.GlacierDays),
+			StorageClass: pulumi.String("GLACIER_IR"),
+		})
+	}
+	return s3.BucketLifecycleConfigurationV2RuleArray{
+		&s3.BucketLifecycleConfigurationV2RuleArgs{
+			Id:     pulumi.String("tier-content"),
+			Status: pulumi.String("Enabled"),
+			Filter: &s3.BucketLifecycleConfigurationV2RuleFilterArgs{
+				And: &s3.BucketLifecycleConfigurationV2RuleFilterAndArgs{
+					Prefix:                pulumi.String(prefix),
+					ObjectSizeGreaterThan: pulumi.Int(c.minSize()),
+				},
+			},
+			Transitions: transitions,
+		},
+	}
+}
+
+// ReplicationConfig replicates the OCFL storage root to a bucket in another
+// region, encrypted with a KMS key in that region. Deletes aren't
+// replicated.
+type ReplicationConfig struct {
+	Region string `json:"region"` // required; not the stack's region
+	// Bucket is the replica bucket (default: <public bucket>-replica)
+	Bucket string `json:"bucket"`
+	// StorageClass of new replicas (default: STANDARD). The public bucket's
+	// tiering also applies to the replica bucket.
+	StorageClass string `json:"storageClass"`
+	// Import adopts an existing replica bucket. It is separate from the
+	// buckets' Import, since the replica is usually created with the stack.
+	Import bool `json:"import"`
+}
+
+func (c *ReplicationConfig) bucket(buckets *BucketsConfig) string {
+	if c.Bucket == "" {
+		return buckets.publicBucket() + "-replica"
+	}
+	return c.Bucket
+}
+
+func (c *ReplicationConfig) storageClass() string {
+	if c.StorageClass == "" {
+		return "STANDARD"
+	}
+	return c.StorageClass
+}
+
+func (c *ReplicationConfig) validate(buckets *BucketsConfig) error {
+	if c == nil {
+		return nil
+	}
+	if c.Region == "" {
+		return fmt.Errorf("replication: region is required")
+	}
+	name := c.bucket(buckets)
+	if !bucketName.MatchString(name) {
+		return fmt.Errorf("replication: invalid bucket name %q", name)
+	}
+	if name == buckets.publicBucket() || name == buckets.restrictedBucket() {
+		return fmt.Errorf("replication: bucket %q is already used", name)
+	}
+	if !slices.Contains(replicaStorageClasses, c.storageClass()) {
+		return fmt.Errorf("replication: invalid storage class %q (use %s)", c.StorageClass, strings.Join(replicaStorageClasses, ", "))
+	}
+	return nil
+}
+
+// newReplication creates the replica bucket and its KMS key in the
+// replication region, and replicates the public prefix of the public bucket
+// to it with an S3 replication role.
+func newReplication(ctx *pulumi.Context, resource string, cfg *BucketsConfig, public *bucket, tiering s3.BucketLifecycleConfigurationV2RuleArray) error {
+	repl := cfg.Replication
+	if region := config.Get(ctx, "aws:region"); region == repl.Region {
+		return fmt.Errorf("%s: replication: region is the stack's region %s", resource, region)
+	}
+	provider, err := aws.NewProvider(ctx, resource+"-replica-provider", &aws.ProviderArgs{
+		Region: pulumi.String(repl.Region),
+	})
+	if err != nil {
+		return err
+	}
+	inRegion := pulumi.Provider(provider)
+	key, err := dreamlab.NewKMSKey(ctx, resource+"-replica-key", "OCFL storage root replica", inRegion)
+	if err != nil {
+		return err
+	}
+	replica, err := newBucket(ctx, resource+"-bucket-replica", cfg, bucketArgs{
+		name:      repl.bucket(cfg),
+		adopt:     repl.Import,
+		kmsKeyArn: key.Arn,
+		rules:     tiering,
+		opts:      []pulumi.ResourceOption{inRegion},
+	})
+	if err != nil {
+		return err
+	}
+	roleResource := resource + "-replication-role"
+	role, err := awsiam.NewRole(ctx, roleResource, &awsiam.RoleArgs{
+		Name:             pulumi.String(roleResource),
+		AssumeRolePolicy: pulumi.String(policyS3AssumeRole),
+	})
+	if err != nil {
+		return err
+	}
+	prefix := cfg.publicPrefix()
+	rolePolicy, err := awsiam.NewRolePolicy(ctx, roleResource+"-policy", &awsiam.RolePolicyArgs{
+		Role: role.Name,
+		Policy: iam.Document(
+			iam.Allow(
+				"s3:GetReplicationConfiguration",
+				"s3:ListBucket",
+			).WithSid("ReplicationSource").On(public.Arn),
+			iam.Allow(
+				"s3:GetObjectVersionForReplication",
+				"s3:GetObjectVersionAcl",
+				"s3:GetObjectVersionTagging",
+			).WithSid("ReplicationSourceObjects").On(pulumi.Sprintf("%s/%s*", public.Arn, prefix)),
+			iam.Allow(
+				"s3:ReplicateObject",
+				"s3:ReplicateDelete",
+				"s3:ReplicateTags",
+			).WithSid("ReplicationDestination").On(pulumi.Sprintf("%s/%s*", replica.Arn, prefix)),
+			iam.Allow(
+				"kms:Encrypt",
+				"kms:GenerateDataKey",
+			).WithSid("ReplicaKey").On(key.Arn),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s3.NewBucketReplicationConfig(ctx, resource+"-bucket-public-replication", &s3.BucketReplicationConfigArgs{
+		Bucket: public.ID(),
+		Role:   role.Arn,
+		Rules: s3.BucketReplicationConfigRuleArray{
+			&s3.BucketReplicationConfigRuleArgs{
+				Id:     pulumi.String("replicate-storage-root"),
+				Status: pulumi.String("Enabled"),
+				Filter: &s3.BucketReplicationConfigRuleFilterArgs{
+					Prefix: pulumi.String(prefix),
+				},
+				DeleteMarkerReplication: &s3.BucketReplicationConfigRuleDeleteMarkerReplicationArgs{
+					Status: pulumi.String("Disabled"),
+				},
+				Destination: &s3.BucketReplicationConfigRuleDestinationArgs{
+					Bucket:       replica.Arn,
+					StorageClass: pulumi.String(repl.storageClass()),
+					EncryptionConfiguration: &s3.BucketReplicationConfigRuleDestinationEncryptionConfigurationArgs{
+						ReplicaKmsKeyId: key.Arn,
+					},
+				},
+			},
+		},
+	}, pulumi.DependsOn([]pulumi.Resource{public.versioning, replica.versioning, rolePolicy}))
+	return err
+}
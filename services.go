@@ -0,0 +1,204 @@
+package main
+
+import (
+	"dreamlab/coder"
+	"dreamlab/internal/dreamlab"
+	"dreamlab/ocfl"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// hostnames are a single DNS label in the stack's zone
+var hostnameLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// serviceConfig enables a service and sets its host's name. It is read
+// from the stack's "services" config map, keyed by service name.
+type serviceConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Hostname string `json:"hostname"` // default: the service name
+}
+
+// stack is the stack config and shared resources passed to services
+type stack struct {
+	config       *config.Config
+	vpc          *dreamlab.AWSVPC
+	dns          *dreamlab.DNS
+	backups      *dreamlab.BackupConfig
+	traefik      *dreamlab.TraefikConfig
+	volumeKeyArn pulumi.StringInput // customer managed key for host volumes
+}
+
+// service creates the resources for a host running a service. The
+// service's name is used as the resource name.
+type service struct {
+	name    string
+	enabled bool // default if the service isn't in the services config
+	new     func(ctx *pulumi.Context, name, hostname string, s *stack) error
+}
+
+// services in the order they are created
+var services = []service{
+	{name: "coder", enabled: true, new: newCoder},
+	{name: "data", enabled: false, new: newData},
+}
+
+// newServices creates the enabled services.
+func newServices(ctx *pulumi.Context, s *stack) error {
+	var configs map[string]serviceConfig
+	if err := s.config.GetObject("services", &configs); err != nil {
+		return err
+	}
+	for name := range configs {
+		if !slices.ContainsFunc(services, func(svc service) bool { return svc.name == name }) {
+			return fmt.Errorf("services: unknown service %q", name)
+		}
+	}
+	// check every service's config before creating any of them
+	hosts := map[string]string{} // enabled services by hostname
+	var enabled []service
+	var hostnames []string
+	for _, svc := range services {
+		cfg, ok := configs[svc.name]
+		if !ok {
+			cfg.Enabled = svc.enabled
+		}
+		if !cfg.Enabled {
+			continue
+		}
+		if cfg.Hostname == "" {
+			cfg.Hostname = svc.name
+		}
+		if !hostnameLabel.MatchString(cfg.Hostname) {
+			return fmt.Errorf("services: %s: invalid hostname %q", svc.name, cfg.Hostname)
+		}
+		if other, ok := hosts[cfg.Hostname]; ok {
+			return fmt.Errorf("services: %s and %s have the same hostname %q", other, svc.name, cfg.Hostname)
+		}
+		hosts[cfg.Hostname] = svc.name
+		enabled = append(enabled, svc)
+		hostnames = append(hostnames, cfg.Hostname)
+	}
+	// the authenticated profile's default forward-auth is the data
+	// service's tinyauth
+	data := slices.ContainsFunc(enabled, func(svc service) bool { return svc.name == "data" })
+	if s.traefik.Dashboard && s.traefik.ForwardAuthURL == "" && !data {
+		return fmt.Errorf("traefik: the dashboard needs forward-auth: enable the data service or set traefik.forwardAuthURL")
+	}
+	for i, svc := range enabled {
+		if err := svc.new(ctx, svc.name, hostnames[i], s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// coder runs the coder server; its settings are the coder_* config keys
+func newCoder(ctx *pulumi.Context, name, hostname string, s *stack) error {
+	var workspaceInstanceTypes, workspaceAMIs []string
+	if err := s.config.GetObject("coder_workspace_instance_types", &workspaceInstanceTypes); err != nil {
+		return err
+	}
+	if err := s.config.GetObject("coder_workspace_amis", &workspaceAMIs); err != nil {
+		return err
+	}
+	var varVolume dreamlab.VolumeConfig
+	var volumes []dreamlab.VolumeConfig
+	if err := s.config.GetObject("coder_var_volume", &varVolume); err != nil {
+		return err
+	}
+	if err := s.config.GetObject("coder_volumes", &volumes); err != nil {
+		return err
+	}
+	var updates dreamlab.UpdatesConfig
+	if err := s.config.GetObject("coder_updates", &updates); err != nil {
+		return err
+	}
+	var autoUpdate dreamlab.AutoUpdateConfig
+	if err := s.config.GetObject("coder_auto_update", &autoUpdate); err != nil {
+		return err
+	}
+	return coder.New(ctx, name, &coder.Config{
+		Hostname:                hostname,
+		VPC:                     s.vpc,
+		DNS:                     s.dns,
+		InstanceAMI:             s.config.Get("coder_instance_ami"),
+		FCOSStream:              s.config.Get("fcos_stream"),
+		Updates:                 &updates,
+		AutoUpdate:              &autoUpdate,
+		Traefik:                 s.traefik,
+		InstanceType:            s.config.Get("coder_instance_type"),
+		WorkspaceInstanceTypes:  workspaceInstanceTypes,
+		WorkspaceAMIs:           workspaceAMIs,
+		WorkspaceDatasetsBucket: s.config.Get("coder_workspace_datasets_bucket"),
+		Backups:                 s.backups,
+		VarVolume:               varVolume,
+		Volumes:                 volumes,
+		KMSKeyArn:               s.volumeKeyArn,
+		RestoreFromSnapshot:     s.config.Get("coder_restore_from_snapshot"),
+	})
+}
+
+// data runs ocfl-server; its settings are the data_* config keys. It uses
+// coder's AMI and instance type unless data_instance_ami and
+// data_instance_type are set.
+func newData(ctx *pulumi.Context, name, hostname string, s *stack) error {
+	var varVolume dreamlab.VolumeConfig
+	var volumes []dreamlab.VolumeConfig
+	if err := s.config.GetObject("data_var_volume", &varVolume); err != nil {
+		return err
+	}
+	if err := s.config.GetObject("data_volumes", &volumes); err != nil {
+		return err
+	}
+	var updates dreamlab.UpdatesConfig
+	if err := s.config.GetObject("data_updates", &updates); err != nil {
+		return err
+	}
+	var autoUpdate dreamlab.AutoUpdateConfig
+	if err := s.config.GetObject("data_auto_update", &autoUpdate); err != nil {
+		return err
+	}
+	var buckets ocfl.BucketsConfig
+	if err := s.config.GetObject("data_buckets", &buckets); err != nil {
+		return err
+	}
+	var access ocfl.AccessConfig
+	if err := s.config.GetObject("data_access", &access); err != nil {
+		return err
+	}
+	var cdn ocfl.CDNConfig
+	if err := s.config.GetObject("data_cdn", &cdn); err != nil {
+		return err
+	}
+	ami := s.config.Get("data_instance_ami")
+	if ami == "" {
+		ami = s.config.Get("coder_instance_ami")
+	}
+	instanceType := s.config.Get("data_instance_type")
+	if instanceType == "" {
+		instanceType = s.config.Get("coder_instance_type")
+	}
+	return ocfl.New(ctx, name, &ocfl.Config{
+		Hostname:            hostname,
+		VPC:                 s.vpc,
+		DNS:                 s.dns,
+		InstanceAMI:         ami,
+		InstanceType:        instanceType,
+		FCOSStream:          s.config.Get("fcos_stream"),
+		Updates:             &updates,
+		AutoUpdate:          &autoUpdate,
+		Traefik:             s.traefik,
+		Buckets:             &buckets,
+		CDN:                 &cdn,
+		Access:              &access,
+		Backups:             s.backups,
+		VarVolume:           varVolume,
+		Volumes:             volumes,
+		KMSKeyArn:           s.volumeKeyArn,
+		RestoreFromSnapshot: s.config.Get("data_restore_from_snapshot"),
+	})
+}
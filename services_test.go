@@ -0,0 +1,160 @@
+package main
+
+import (
+	"dreamlab/internal/dreamlab"
+	"dreamlab/internal/dreamlab/pulumitest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// servicesConfig is the stack config both services need, without the
+// "services" key. Containers are auto-updated, so rendering doesn't depend
+// on the image manifest being pinned.
+var servicesConfig = map[string]string{
+	"aws:region":                     "us-west-2",
+	"acme":                           `{"email": "admin@ucsb.edu"}`,
+	"traefik":                        `{}`,
+	"coder_instance_ami":             "ami-0123456789abcdef0",
+	"coder_instance_type":            "t4g.small",
+	"coder_var_volume":               `{"size": 64}`,
+	"coder_auto_update":              `{"services": ["coder", "traefik"]}`,
+	"coder_workspace_instance_types": `["t3.small"]`,
+	"coder_workspace_amis":           `["ami-0cf2b4e024cdb6960"]`,
+	"data_var_volume":                `{"size": 32}`,
+	"data_auto_update":               `{"services": ["traefik", "tinyauth", "ocfl-server"]}`,
+	"data_access":                    `{"write": ["admin@ucsb.edu"]}`,
+	"googleOAuth2ClientID":           "client-id",
+	"googleOAuth2ClientSecret":       "client-secret",
+	"LSITClusterServer":              "https://rancher.example.edu",
+	"LSITClusterToken":               "cluster-token",
+	"LSITOuterRimToken":              "outer-rim-token",
+	"DataAppSecret":                  strings.Repeat("s", 32),
+}
+
+// runServices runs newServices with the services config under mocks and
+// returns the names of the resources it registered, which aren't in
+// baseline.
+func runServices(t *testing.T, services string, baseline []string) []string {
+	t.Helper()
+	cfg := map[string]string{"services": services}
+	for k, v := range servicesConfig {
+		cfg[k] = v
+	}
+	mocks := &pulumitest.Mocks{
+		Calls: map[string]func(resource.PropertyMap) resource.PropertyMap{
+			"aws:ec2/getAmi:getAmi": func(resource.PropertyMap) resource.PropertyMap {
+				return resource.NewPropertyMapFromMap(map[string]any{"id": "ami-0123456789abcdef0", "architecture": "arm64"})
+			},
+		},
+	}
+	err := pulumitest.Run(mocks, cfg, func(ctx *pulumi.Context) error {
+		vpc, err := dreamlab.NewAWSVPC(ctx)
+		if err != nil {
+			return err
+		}
+		dns, err := dreamlab.NewDNSZone(ctx)
+		if err != nil {
+			return err
+		}
+		stackConfig := config.New(ctx, "")
+		var traefik dreamlab.TraefikConfig
+		if err := stackConfig.GetObject("traefik", &traefik); err != nil {
+			return err
+		}
+		traefik.ACME = &dreamlab.ACMEConfig{Email: "admin@ucsb.edu"}
+		return newServices(ctx, &stack{
+			config:  stackConfig,
+			vpc:     vpc,
+			dns:     dns,
+			backups: &dreamlab.BackupConfig{},
+			traefik: &traefik,
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range mocks.Resources() {
+		if !slices.Contains(baseline, r.Name) {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+// The dashboard's default forward-auth is the data service's tinyauth.
+func TestNewServicesDashboardAuth(t *testing.T) {
+	tests := []struct {
+		name           string
+		services       string
+		forwardAuthURL string
+		wantErr        string
+	}{
+		{
+			name:     "no data service",
+			services: `{"coder": {"enabled": true}}`,
+			wantErr:  "the dashboard needs forward-auth",
+		},
+		{
+			name:           "forward-auth url",
+			services:       `{"coder": {"enabled": false}}`,
+			forwardAuthURL: "https://auth.example.edu/api/auth/traefik",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := pulumitest.Run(&pulumitest.Mocks{}, map[string]string{"services": tt.services}, func(ctx *pulumi.Context) error {
+				return newServices(ctx, &stack{
+					config:  config.New(ctx, ""),
+					traefik: &dreamlab.TraefikConfig{Dashboard: true, ForwardAuthURL: tt.forwardAuthURL},
+				})
+			})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("newServices() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// A disabled service registers no resources.
+func TestNewServices(t *testing.T) {
+	count := func(names []string, prefix string) int {
+		n := 0
+		for _, name := range names {
+			if strings.HasPrefix(name, prefix) {
+				n++
+			}
+		}
+		return n
+	}
+	// the VPC and DNS zone
+	shared := runServices(t, `{"coder": {"enabled": false}}`, nil)
+	if n := count(shared, "coder") + count(shared, "data"); n != 0 {
+		t.Errorf("no services: registered %d service resources", n)
+	}
+	coderOnly := runServices(t, `{"coder": {"enabled": true}}`, shared)
+	both := runServices(t, `{"coder": {"enabled": true}, "data": {"enabled": true}}`, shared)
+	if count(coderOnly, "coder") == 0 {
+		t.Error("coder: no resources")
+	}
+	if n := count(coderOnly, "data"); n != 0 {
+		t.Errorf("data disabled: registered %d resources", n)
+	}
+	if count(both, "coder") != count(coderOnly, "coder") {
+		t.Errorf("coder registered %d resources with data, %d without", count(both, "coder"), count(coderOnly, "coder"))
+	}
+	if n := count(both, "data"); n == 0 || len(both) != len(coderOnly)+n {
+		t.Errorf("data enabled: registered %d data resources, %d in all (%d without data)", n, len(both), len(coderOnly))
+	}
+}